@@ -1,13 +1,17 @@
 package acc
 
 import (
+	"context"
 	"encoding/base64"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
 	"github.com/loafoe/terraform-provider-ssh/ssh"
 )
 
@@ -29,6 +33,10 @@ func Skip(t *testing.T, message string) {
 // for tests requiring special provider configurations.
 var ProviderFactories map[string]func() (*schema.Provider, error)
 
+// ProtoV5ProviderFactories contains the muxed SDK and plugin framework provider.
+// Use these for tests of resources which are implemented with the plugin framework
+var ProtoV5ProviderFactories map[string]func() (tfprotov5.ProviderServer, error)
+
 // testAccProviderConfigure ensures Provider is only configured once
 //
 // The PreCheck(t) function is invoked for every test and this prevents
@@ -43,6 +51,15 @@ func init() {
 	ProviderFactories = map[string]func() (*schema.Provider, error){
 		ProviderName: func() (*schema.Provider, error) { return ssh.Provider(), nil }, //nolint:unparam
 	}
+	ProtoV5ProviderFactories = map[string]func() (tfprotov5.ProviderServer, error){
+		ProviderName: func() (tfprotov5.ProviderServer, error) {
+			factory, err := ssh.ProviderServerFactory(context.Background())
+			if err != nil {
+				return nil, err
+			}
+			return factory(), nil
+		},
+	}
 }
 
 // PreCheck verifies and sets required provider testing configuration
@@ -57,9 +74,9 @@ func PreCheck(t *testing.T) {
 	// Since we are outside the scope of the Terraform configuration we must
 	// call Configure() to properly initialize the provider configuration.
 	testAccProviderConfigure.Do(func() {
-		// TODO: add additional pre-checks here
+		// Without SSH_ACC_HOSTNAME tests run against an in-process sshtest.Server
 		if AccHostname() == "" {
-			t.Fatalf("SSH_ACC_HOSTNAME be set")
+			return
 		}
 		if AccUsername() == "" {
 			t.Fatalf("SSH_ACC_USERNAME must be set")
@@ -67,17 +84,64 @@ func PreCheck(t *testing.T) {
 		if AccPrivateKey() == "" {
 			t.Fatalf("SSH_ACC_PRIVATE_KEY_BASE64 must be set")
 		}
+		if port, err := strconv.Atoi(AccPort()); err != nil || port < 1 || port > 65535 {
+			t.Fatalf("SSH_ACC_PORT must be a port number, got %q", AccPort())
+		}
 	})
 }
 
+// Target is the SSH host acceptance tests run against
+type Target struct {
+	Host       string
+	Port       string
+	User       string
+	PrivateKey string
+	// Dir is a directory on the host tests can write files to
+	Dir string
+	// Server is the in-process server, or nil when testing against SSH_ACC_HOSTNAME
+	Server *sshtest.Server
+}
+
+// NewTarget returns the host configured with SSH_ACC_HOSTNAME and SSH_ACC_PORT, or starts an in-process sshtest.Server
+func NewTarget(t *testing.T) Target {
+	t.Helper()
+	if AccHostname() != "" {
+		return Target{
+			Host:       AccHostname(),
+			Port:       AccPort(),
+			User:       AccUsername(),
+			PrivateKey: AccPrivateKey(),
+			Dir:        "/tmp",
+		}
+	}
+	server := sshtest.NewServer(t)
+	return Target{
+		Host:       server.Host,
+		Port:       server.Port,
+		User:       server.User,
+		PrivateKey: server.PrivateKey,
+		Dir:        server.Dir,
+		Server:     server,
+	}
+}
+
 func AccHostname() string {
 	return os.Getenv("SSH_ACC_HOSTNAME")
 }
 
+// AccPort returns SSH_ACC_PORT, which defaults to 22
+func AccPort() string {
+	if port := os.Getenv("SSH_ACC_PORT"); port != "" {
+		return port
+	}
+	return "22"
+}
+
 func AccUsername() string {
 	return os.Getenv("SSH_ACC_USERNAME")
 }
 
+// AccPrivateKey returns the PEM encoded private key decoded from SSH_ACC_PRIVATE_KEY_BASE64
 func AccPrivateKey() string {
 	encoded := os.Getenv("SSH_ACC_PRIVATE_KEY_BASE64")
 	if encoded == "" {
@@ -87,5 +151,5 @@ func AccPrivateKey() string {
 	if err != nil {
 		return ""
 	}
-	return strings.ReplaceAll(string(decoded), "\n", "")
+	return strings.TrimSpace(string(decoded))
 }
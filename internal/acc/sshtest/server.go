@@ -0,0 +1,547 @@
+// Package sshtest provides an in-process SSH server for tests
+package sshtest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Faults are hooks to inject failures into a Server. Hooks which are nil are not called.
+// Connection and transfer hooks are called with a 1-based count, so FirstN and Always can
+// be used to script them
+type Faults struct {
+	// RejectConnection is called for each accepted connection. The connection is closed
+	// before the handshake when it returns true
+	RejectConnection func(n int) bool
+	// HangHandshake is called for each accepted connection. The server never answers the
+	// handshake when it returns true, the connection stays open until the client gives up
+	HangHandshake func(n int) bool
+	// RejectAuth is called for each accepted connection. All authentication attempts fail
+	// when it returns true, which clients report as "no supported methods remain"
+	RejectAuth func(n int) bool
+	// DropTransfer is called for each file received with scp. The connection is closed
+	// after half of the content is written when it returns true
+	DropTransfer func(n int) bool
+	// Command is called for each exec request. When handled is true the command is not
+	// run and exits with exitStatus
+	Command func(command string) (exitStatus uint32, handled bool)
+	// RejectSFTP rejects requests for the sftp subsystem, as servers which do not offer it do
+	RejectSFTP bool
+}
+
+// FirstN returns a hook which applies a fault to the first n connections or transfers
+func FirstN(n int) func(int) bool {
+	return func(i int) bool {
+		return i <= n
+	}
+}
+
+// Always is a hook which applies a fault to every connection or transfer
+func Always(int) bool {
+	return true
+}
+
+// ExitWith returns a Command hook which makes command exit with status without running it
+func ExitWith(command string, status uint32) func(string) (uint32, bool) {
+	return func(c string) (uint32, bool) {
+		return status, c == command
+	}
+}
+
+// errTransferDropped is returned by the scp sink when a DropTransfer fault applies
+var errTransferDropped = errors.New("transfer dropped")
+
+// Server is an in-process SSH server for hermetic acceptance tests. Commands are run by the
+// local shell in Dir, files are written with scp or sftp and relative paths resolve to Dir.
+// Clients authenticate as User with Password, PrivateKey or a certificate signed by the
+// server, and can open direct-tcpip channels so the server can act as a bastion
+type Server struct {
+	Host       string
+	Port       string
+	User       string
+	Password   string
+	PrivateKey string
+	Dir        string
+	HostKey    gossh.PublicKey
+
+	mu          sync.Mutex
+	faults      Faults
+	connections int
+	transfers   int
+	commands    []string
+	open        map[net.Conn]struct{}
+
+	listener  net.Listener
+	config    *gossh.ServerConfig
+	hostKey   gossh.Signer
+	authority gossh.Signer
+	wg        sync.WaitGroup
+}
+
+// NewServer starts a Server on a random local port which is closed when t completes
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+	hostKey := newSigner(t)
+	authority := newSigner(t)
+	clientKey, clientKeyPEM := newPrivateKey(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("starting SSH server: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	s := &Server{
+		Host:       host,
+		Port:       port,
+		User:       "terraform",
+		Password:   "terraform",
+		PrivateKey: clientKeyPEM,
+		Dir:        t.TempDir(),
+		HostKey:    hostKey.PublicKey(),
+		open:       make(map[net.Conn]struct{}),
+		listener:   listener,
+		hostKey:    hostKey,
+		authority:  authority,
+	}
+	checker := &gossh.CertChecker{
+		IsUserAuthority: func(auth gossh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), authority.PublicKey().Marshal())
+		},
+		UserKeyFallback: func(conn gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if conn.User() == s.User && bytes.Equal(key.Marshal(), clientKey.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown public key for %s", conn.User())
+		},
+	}
+	s.config = &gossh.ServerConfig{
+		PasswordCallback: func(conn gossh.ConnMetadata, password []byte) (*gossh.Permissions, error) {
+			if conn.User() == s.User && string(password) == s.Password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", conn.User())
+		},
+		PublicKeyCallback: checker.Authenticate,
+	}
+	s.config.AddHostKey(hostKey)
+
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close stops the server. Open connections are closed, clients do not always close them
+func (s *Server) Close() {
+	_ = s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.open {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Address returns the host:port the server listens on
+func (s *Server) Address() string {
+	return net.JoinHostPort(s.Host, s.Port)
+}
+
+// SetFaults replaces the fault injection hooks
+func (s *Server) SetFaults(faults Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = faults
+}
+
+// Connections returns the number of connections accepted so far
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+// Transfers returns the number of files received with scp so far, including dropped ones
+func (s *Server) Transfers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.transfers
+}
+
+// Commands returns the commands executed so far, in order
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Path returns the absolute path of name in Dir
+func (s *Server) Path(name string) string {
+	return filepath.Join(s.Dir, name)
+}
+
+// CertSigner returns a signer for a new key with a user certificate signed by the server
+// for the given principals
+func (s *Server) CertSigner(t *testing.T, principals ...string) gossh.Signer {
+	t.Helper()
+	signer := newSigner(t)
+	cert := &gossh.Certificate{
+		Key:             signer.PublicKey(),
+		CertType:        gossh.UserCert,
+		KeyId:           "acc",
+		ValidPrincipals: principals,
+		ValidBefore:     gossh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, s.authority); err != nil {
+		t.Fatalf("signing certificate: %v", err)
+	}
+	certSigner, err := gossh.NewCertSigner(cert, signer)
+	if err != nil {
+		t.Fatalf("creating certificate signer: %v", err)
+	}
+	return certSigner
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.connections++
+		n := s.connections
+		faults := s.faults
+		s.mu.Unlock()
+		if faults.RejectConnection != nil && faults.RejectConnection(n) {
+			_ = conn.Close()
+			continue
+		}
+		hang := faults.HangHandshake != nil && faults.HangHandshake(n)
+		rejectAuth := faults.RejectAuth != nil && faults.RejectAuth(n)
+		s.mu.Lock()
+		s.open[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handleConn(conn, hang, rejectAuth)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn, hang, rejectAuth bool) {
+	defer s.wg.Done()
+	defer func() {
+		_ = conn.Close()
+		s.mu.Lock()
+		delete(s.open, conn)
+		s.mu.Unlock()
+	}()
+
+	if hang {
+		_, _ = io.Copy(io.Discard, conn)
+		return
+	}
+	config := s.config
+	if rejectAuth {
+		config = &gossh.ServerConfig{
+			PasswordCallback: func(gossh.ConnMetadata, []byte) (*gossh.Permissions, error) {
+				return nil, errors.New("authentication rejected")
+			},
+			PublicKeyCallback: func(gossh.ConnMetadata, gossh.PublicKey) (*gossh.Permissions, error) {
+				return nil, errors.New("authentication rejected")
+			},
+		}
+		config.AddHostKey(s.hostKey)
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	serverConn, channels, requests, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+	defer serverConn.Close()
+	go gossh.DiscardRequests(requests)
+
+	var channelsWg sync.WaitGroup
+	for newChannel := range channels {
+		switch newChannel.ChannelType() {
+		case "session":
+			channelsWg.Add(1)
+			go func() {
+				defer channelsWg.Done()
+				s.handleSession(newChannel, conn)
+			}()
+		case "direct-tcpip":
+			channelsWg.Add(1)
+			go func() {
+				defer channelsWg.Done()
+				handleDirectTCPIP(newChannel)
+			}()
+		default:
+			_ = newChannel.Reject(gossh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+	channelsWg.Wait()
+}
+
+func (s *Server) handleSession(newChannel gossh.NewChannel, conn net.Conn) {
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			if err := gossh.Unmarshal(req.Payload, &payload); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			sendExitStatus(channel, s.exec(channel, conn, payload.Command))
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			s.mu.Lock()
+			rejectSFTP := s.faults.RejectSFTP
+			s.mu.Unlock()
+			if err := gossh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" || rejectSFTP {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(s.Dir))
+			if err != nil {
+				sendExitStatus(channel, 1)
+				return
+			}
+			var status uint32
+			if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+				status = 1
+			}
+			sendExitStatus(channel, status)
+			return
+		case "env", "pty-req":
+			_ = req.Reply(true, nil)
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+// exec runs command and returns its exit status. scp sink commands are handled in process
+func (s *Server) exec(channel gossh.Channel, conn net.Conn, command string) uint32 {
+	s.mu.Lock()
+	s.commands = append(s.commands, command)
+	fault := s.faults.Command
+	s.mu.Unlock()
+	if fault != nil {
+		if exitStatus, handled := fault(command); handled {
+			return exitStatus
+		}
+	}
+
+	if target, ok := scpSinkTarget(command); ok {
+		if err := s.scpSink(channel, conn, target); err != nil {
+			_, _ = fmt.Fprintf(channel.Stderr(), "scp: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = s.Dir
+	cmd.Env = append(os.Environ(), "HOME="+s.Dir, "USER="+s.User)
+	cmd.Stdout = channel
+	cmd.Stderr = channel.Stderr()
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return uint32(exitErr.ExitCode())
+		}
+		_, _ = fmt.Fprintf(channel.Stderr(), "%v\n", err)
+		return 127
+	}
+	return 0
+}
+
+// scpSinkTarget returns the target of an 'scp -t' command
+func scpSinkTarget(command string) (string, bool) {
+	fields := strings.Fields(command)
+	if len(fields) < 3 || fields[0] != "scp" {
+		return "", false
+	}
+	for _, f := range fields[1 : len(fields)-1] {
+		if strings.HasPrefix(f, "-") && strings.Contains(f, "t") {
+			return fields[len(fields)-1], true
+		}
+	}
+	return "", false
+}
+
+// scpSink receives files sent with the scp protocol. Only single files are supported
+func (s *Server) scpSink(channel gossh.Channel, conn net.Conn, target string) error {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(s.Dir, target)
+	}
+	reader := bufio.NewReader(channel)
+	ack := func() error {
+		_, err := channel.Write([]byte{0})
+		return err
+	}
+	if err := ack(); err != nil {
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch line[0] {
+		case 'C':
+			fields := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+			if len(fields) != 3 {
+				return fmt.Errorf("invalid file record %q", line)
+			}
+			mode, err := strconv.ParseUint(fields[0], 8, 32)
+			if err != nil {
+				return err
+			}
+			size, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			path := target
+			if info, err := os.Stat(target); err == nil && info.IsDir() {
+				path = filepath.Join(target, fields[2])
+			}
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(mode))
+			if err != nil {
+				return err
+			}
+			s.mu.Lock()
+			s.transfers++
+			drop := s.faults.DropTransfer != nil && s.faults.DropTransfer(s.transfers)
+			s.mu.Unlock()
+			if drop {
+				_, _ = io.CopyN(f, reader, size/2)
+				_ = f.Close()
+				_ = conn.Close()
+				return errTransferDropped
+			}
+			if _, err := io.CopyN(f, reader, size); err != nil {
+				_ = f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			if _, err := reader.ReadByte(); err != nil {
+				return err
+			}
+			if err := ack(); err != nil {
+				return err
+			}
+		case 'T':
+			if err := ack(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported record %q", line)
+		}
+	}
+}
+
+func handleDirectTCPIP(newChannel gossh.NewChannel) {
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := gossh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+		_ = newChannel.Reject(gossh.ConnectionFailed, err.Error())
+		return
+	}
+	remote, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.FormatUint(uint64(target.Port), 10)))
+	if err != nil {
+		_ = newChannel.Reject(gossh.ConnectionFailed, err.Error())
+		return
+	}
+	defer remote.Close()
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	go gossh.DiscardRequests(requests)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(channel, remote)
+		_ = channel.CloseWrite()
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(remote, channel)
+		if tcp, ok := remote.(*net.TCPConn); ok {
+			_ = tcp.CloseWrite()
+		}
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+func sendExitStatus(channel gossh.Channel, status uint32) {
+	_, _ = channel.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{status}))
+}
+
+func newSigner(t *testing.T) gossh.Signer {
+	t.Helper()
+	signer, _ := newPrivateKey(t)
+	return signer
+}
+
+// newPrivateKey returns a new ed25519 key as signer and in OpenSSH PEM format
+func newPrivateKey(t *testing.T) (gossh.Signer, string) {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	signer, err := gossh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("creating signer: %v", err)
+	}
+	block, err := gossh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatalf("marshalling key: %v", err)
+	}
+	return signer, string(pem.EncodeToMemory(block))
+}
@@ -0,0 +1,242 @@
+package sshtest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loafoe/easyssh-proxy/v2"
+	"github.com/pkg/sftp"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func testClientConfig(s *Server, auth ...gossh.AuthMethod) *gossh.ClientConfig {
+	return &gossh.ClientConfig{
+		User:            s.User,
+		Auth:            auth,
+		HostKeyCallback: gossh.FixedHostKey(s.HostKey),
+		Timeout:         5 * time.Second,
+	}
+}
+
+func TestServer_exec(t *testing.T) {
+	s := NewServer(t)
+	ssh := &easyssh.MakeConfig{User: s.User, Server: s.Host, Port: s.Port, Key: s.PrivateKey}
+
+	stdout, _, _, err := ssh.Run("echo hello > greeting && cat greeting", 10*time.Second)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if stdout != "hello\n" {
+		t.Errorf("expected stdout %q, got %q", "hello\n", stdout)
+	}
+	if _, err := os.Stat(s.Path("greeting")); err != nil {
+		t.Errorf("expected command to run in server directory: %v", err)
+	}
+
+	_, _, _, err = ssh.Run("exit 3", 10*time.Second)
+	var exitErr *gossh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 3 {
+		t.Errorf("expected exit status 3, got %v", err)
+	}
+	if got := s.Commands(); len(got) != 2 || got[1] != "exit 3" {
+		t.Errorf("unexpected recorded commands %q", got)
+	}
+}
+
+func TestServer_scp(t *testing.T) {
+	s := NewServer(t)
+	ssh := &easyssh.MakeConfig{User: s.User, Server: s.Host, Port: s.Port, Password: s.Password}
+
+	content := "scp content"
+	if err := ssh.WriteFile(strings.NewReader(content), int64(len(content)), s.Path("scp.txt")); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	data, err := os.ReadFile(s.Path("scp.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("expected %q, got %q", content, data)
+	}
+}
+
+func TestServer_sftp(t *testing.T) {
+	s := NewServer(t)
+	client, err := gossh.Dial("tcp", s.Address(), testClientConfig(s, gossh.Password(s.Password)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		t.Fatalf("sftp: %v", err)
+	}
+	defer sftpClient.Close()
+
+	f, err := sftpClient.Create("relative.txt")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	_, _ = f.Write([]byte("sftp content"))
+	_ = f.Close()
+
+	data, err := os.ReadFile(s.Path("relative.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "sftp content" {
+		t.Errorf("expected %q, got %q", "sftp content", data)
+	}
+}
+
+func TestServer_rejectSFTP(t *testing.T) {
+	s := NewServer(t)
+	s.SetFaults(Faults{RejectSFTP: true})
+	client, err := gossh.Dial("tcp", s.Address(), testClientConfig(s, gossh.Password(s.Password)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	if _, err := sftp.NewClient(client); err == nil {
+		t.Errorf("expected the sftp subsystem to be rejected")
+	}
+}
+
+func TestServer_auth(t *testing.T) {
+	s := NewServer(t)
+	signer, err := gossh.ParsePrivateKey([]byte(s.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner, _ := newPrivateKey(t)
+
+	cases := map[string]struct {
+		user    string
+		auth    gossh.AuthMethod
+		success bool
+	}{
+		"password":              {s.User, gossh.Password(s.Password), true},
+		"wrong password":        {s.User, gossh.Password("wrong"), false},
+		"key":                   {s.User, gossh.PublicKeys(signer), true},
+		"unknown key":           {s.User, gossh.PublicKeys(otherSigner), false},
+		"certificate":           {s.User, gossh.PublicKeys(s.CertSigner(t, s.User)), true},
+		"certificate principal": {s.User, gossh.PublicKeys(s.CertSigner(t, "other")), false},
+		"wrong user":            {"other", gossh.PublicKeys(signer), false},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := testClientConfig(s, c.auth)
+			config.User = c.user
+			client, err := gossh.Dial("tcp", s.Address(), config)
+			if err == nil {
+				_ = client.Close()
+			}
+			if (err == nil) != c.success {
+				t.Errorf("expected success %t, got %v", c.success, err)
+			}
+		})
+	}
+}
+
+func TestServer_bastion(t *testing.T) {
+	bastion := NewServer(t)
+	s := NewServer(t)
+	ssh := &easyssh.MakeConfig{
+		User:   s.User,
+		Server: s.Host,
+		Port:   s.Port,
+		Key:    s.PrivateKey,
+		Bastion: easyssh.DefaultConfig{
+			User:     bastion.User,
+			Server:   bastion.Host,
+			Port:     bastion.Port,
+			Password: bastion.Password,
+		},
+	}
+	stdout, _, _, err := ssh.Run("echo via bastion", 10*time.Second)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if stdout != "via bastion\n" {
+		t.Errorf("expected stdout %q, got %q", "via bastion\n", stdout)
+	}
+	if bastion.Connections() != 1 || len(bastion.Commands()) != 0 {
+		t.Errorf("expected a single forwarding connection to the bastion, got %d connections and commands %q",
+			bastion.Connections(), bastion.Commands())
+	}
+}
+
+func TestServer_faults(t *testing.T) {
+	s := NewServer(t)
+	s.SetFaults(Faults{
+		RejectConnection: FirstN(1),
+		Command:          ExitWith("fail", 42),
+	})
+	config := testClientConfig(s, gossh.Password(s.Password))
+
+	if _, err := gossh.Dial("tcp", s.Address(), config); err == nil {
+		t.Fatal("expected first connection to be rejected")
+	}
+	client, err := gossh.Dial("tcp", s.Address(), config)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+	err = session.Run("fail")
+	var exitErr *gossh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 42 {
+		t.Errorf("expected injected exit status 42, got %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected injected command not to run, got output %q", stdout.String())
+	}
+}
+
+func TestServer_directTCPIP(t *testing.T) {
+	s := NewServer(t)
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		conn, err := echo.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	client, err := gossh.Dial("tcp", s.Address(), testClientConfig(s, gossh.Password(s.Password)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	conn, err := client.Dial("tcp", echo.Addr().String())
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("expected echo %q, got %q", "ping", buf)
+	}
+}
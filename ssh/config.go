@@ -3,11 +3,31 @@ package ssh
 import (
 	"fmt"
 	"os"
+	"sync"
 )
 
 type Config struct {
 	DebugLog  string
 	debugFile *os.File
+
+	// failedCreates holds the progress of resources whose create failed, see keepFailedCreate
+	mu            sync.Mutex
+	failedCreates map[string]*sshResourceModel
+}
+
+func newConfig(debugLog string) *Config {
+	config := &Config{
+		DebugLog: debugLog,
+	}
+	if config.DebugLog != "" {
+		debugFile, err := os.OpenFile(config.DebugLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			config.debugFile = nil
+		} else {
+			config.debugFile = debugFile
+		}
+	}
+	return config
 }
 
 func (c *Config) Debug(format string, args ...interface{}) (int, error) {
@@ -0,0 +1,318 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/loafoe/easyssh-proxy/v2"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// connectionSchema returns the arguments needed to connect to a host.
+// These mirror the connection arguments of ssh_resource, except for its write-only credentials:
+// resources using these connect on refresh and destroy, when write-only values are not available
+func connectionSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"host": {
+			Type:     schema.TypeString,
+			Required: true,
+		},
+		"port": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      22,
+			ValidateFunc: validation.IsPortNumber,
+		},
+		"bastion_host": {
+			Type:     schema.TypeString,
+			Optional: true,
+		},
+		"bastion_port": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      22,
+			ValidateFunc: validation.IsPortNumber,
+		},
+		"user": {
+			Type:     schema.TypeString,
+			Required: true,
+		},
+		"bastion_user": {
+			Type:     schema.TypeString,
+			Optional: true,
+		},
+		"password": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"bastion_password": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"private_key": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"bastion_private_key": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"agent": {
+			Type:     schema.TypeBool,
+			Optional: true,
+			Default:  false,
+		},
+		"timeout": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  "5m",
+		},
+		"retry_delay": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  "10s",
+		},
+		"ignore_no_supported_methods_remain": {
+			Type:     schema.TypeBool,
+			Optional: true,
+			Default:  false,
+		},
+	}
+}
+
+// mergeSchema returns a new schema map containing the fields of all given schemas
+func mergeSchema(schemas ...map[string]*schema.Schema) map[string]*schema.Schema {
+	merged := make(map[string]*schema.Schema)
+	for _, s := range schemas {
+		for k, v := range s {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// connectionSettings holds the arguments needed to connect to a host
+type connectionSettings struct {
+	Host              string
+	Port              string
+	User              string
+	HostUser          string
+	Password          string
+	PrivateKey        string
+	HostPrivateKey    string
+	BastionHost       string
+	BastionPort       string
+	BastionUser       string
+	BastionPassword   string
+	BastionPrivateKey string
+
+	// Passphrases of the private keys, taken from the environment when empty
+	PrivateKeyPassphrase        string
+	BastionPrivateKeyPassphrase string
+}
+
+// newSSHConfig builds the easyssh configuration from the connection arguments.
+// The deprecated 'host_user' and 'host_private_key' arguments are honoured when present
+func newSSHConfig(d *schema.ResourceData) *easyssh.MakeConfig {
+	hostUser, _ := d.Get("host_user").(string)
+	hostPrivateKey, _ := d.Get("host_private_key").(string)
+
+	return connectionSettings{
+		Host:              d.Get("host").(string),
+		Port:              strconv.Itoa(d.Get("port").(int)),
+		User:              d.Get("user").(string),
+		HostUser:          hostUser,
+		Password:          d.Get("password").(string),
+		PrivateKey:        d.Get("private_key").(string),
+		HostPrivateKey:    hostPrivateKey,
+		BastionHost:       d.Get("bastion_host").(string),
+		BastionPort:       strconv.Itoa(d.Get("bastion_port").(int)),
+		BastionUser:       d.Get("bastion_user").(string),
+		BastionPassword:   d.Get("bastion_password").(string),
+		BastionPrivateKey: d.Get("bastion_private_key").(string),
+	}.sshConfig()
+}
+
+// sshConfig builds the easyssh configuration. Passphrases of private keys which are not set
+// are taken from the environment
+func (c connectionSettings) sshConfig() *easyssh.MakeConfig {
+	privateKeyPassphrase := c.PrivateKeyPassphrase
+	if privateKeyPassphrase == "" {
+		privateKeyPassphrase = os.Getenv("SSH_PRIVATE_KEY_PASSPHRASE")
+	}
+	bastionPrivateKeyPassphrase := c.BastionPrivateKeyPassphrase
+	if bastionPrivateKeyPassphrase == "" {
+		bastionPrivateKeyPassphrase = os.Getenv("SSH_BASTION_PRIVATE_KEY_PASSPHRASE")
+	}
+
+	hostUser := c.HostUser
+	if len(hostUser) == 0 {
+		hostUser = c.User
+	}
+	hostPrivateKey := c.HostPrivateKey
+	if len(hostPrivateKey) == 0 {
+		hostPrivateKey = c.PrivateKey
+	}
+
+	ssh := &easyssh.MakeConfig{
+		User:       hostUser,
+		Server:     normalizeHost(c.Host),
+		Port:       c.Port,
+		Key:        c.PrivateKey,
+		Passphrase: privateKeyPassphrase,
+		Proxy:      http.ProxyFromEnvironment,
+		Bastion: easyssh.DefaultConfig{
+			User:       c.User,
+			Server:     normalizeHost(c.BastionHost),
+			Passphrase: bastionPrivateKeyPassphrase,
+			Port:       c.BastionPort,
+		},
+	}
+	if c.Password != "" {
+		ssh.Password = c.Password
+	}
+	if c.BastionPassword != "" {
+		ssh.Bastion.Password = c.BastionPassword
+	}
+	if c.BastionUser != "" {
+		ssh.Bastion.User = c.BastionUser
+	}
+	if hostPrivateKey != "" {
+		ssh.Key = hostPrivateKey
+	}
+	if c.PrivateKey != "" {
+		ssh.Bastion.Key = c.PrivateKey
+	}
+	if c.BastionPrivateKey != "" {
+		ssh.Bastion.Key = c.BastionPrivateKey
+	}
+	return ssh
+}
+
+// normalizeHost returns host without the brackets which may enclose an IPv6 address, as these
+// are added when the host is joined with a port
+func normalizeHost(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// newSSHRetryConfig returns the retry configuration from the connection arguments
+func newSSHRetryConfig(d *schema.ResourceData) (SSHRetryConfig, error) {
+	var sshRetryConfig SSHRetryConfig
+	var err error
+
+	sshRetryConfig.timeout, err = time.ParseDuration(d.Get("timeout").(string))
+	if err != nil {
+		return sshRetryConfig, fmt.Errorf("timeout value: %w", err)
+	}
+	sshRetryConfig.retryDelay, err = time.ParseDuration(d.Get("retry_delay").(string))
+	if err != nil {
+		return sshRetryConfig, fmt.Errorf("retry_delay value: %w", err)
+	}
+	sshRetryConfig.ignoreUnsupportedAuthMethods = d.Get("ignore_no_supported_methods_remain").(bool)
+	return sshRetryConfig, nil
+}
+
+// errWaitingForHost is returned by withContext when a call is abandoned
+var errWaitingForHost = errors.New("waiting for host")
+
+// withContext returns the result of f, or the error of ctx when it is done first.
+// easyssh does not time out SSH handshakes, so without this a host which accepts connections
+// but never completes the handshake blocks forever. The abandoned call ends with its connection
+func withContext[T any](ctx context.Context, f func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	results := make(chan result, 1)
+	go func() {
+		value, err := f()
+		results <- result{value, err}
+	}()
+	select {
+	case r := <-results:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, fmt.Errorf("%w: %w", errWaitingForHost, ctx.Err())
+	}
+}
+
+// runWithContext runs command like ssh.Run, returning when ctx is done
+func runWithContext(ctx context.Context, ssh *easyssh.MakeConfig, command string, timeout time.Duration) (commandResult, bool, error) {
+	type output struct {
+		result commandResult
+		done   bool
+	}
+	out, err := withContext(ctx, func() (output, error) {
+		stdout, stderr, done, err := ssh.Run(command, timeout)
+		return output{commandResult{Stdout: stdout, Stderr: stderr}, done}, err
+	})
+	return out.result, out.done, err
+}
+
+// commandResult holds the outcome of a single remote command
+type commandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// runCommand runs a single command, retrying on connection errors until ctx is done.
+// Unlike runCommands a command that ran and exited with a non-zero status is not retried,
+// its exit code is returned in the result instead
+func runCommand(ctx context.Context, command string, ssh *easyssh.MakeConfig, sshRetryConfig SSHRetryConfig, config *Config) (commandResult, error) {
+	for {
+		result, done, err := runWithContext(ctx, ssh, command, sshRetryConfig.timeout)
+		_, _ = config.Debug("command: %s\ndone: %t\nstdout:\n%s\nstderr:\n%s\nerror: %v\n", command, done, result.Stdout, result.Stderr, err)
+		if err == nil {
+			return result, nil
+		}
+		var exitErr *gossh.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitStatus()
+			return result, nil
+		}
+		if !sshRetryConfig.ignoreUnsupportedAuthMethods && strings.Contains(err.Error(), "no supported methods remain") {
+			return result, err
+		}
+		select {
+		case <-time.After(sshRetryConfig.retryDelay):
+			// Retry
+		case <-ctx.Done():
+			return result, fmt.Errorf("%s: %w", ctx.Err(), err)
+		}
+	}
+}
+
+// diagFromCommandError returns the diagnostics for a command which could not be run
+func diagFromCommandError(command string, result commandResult, err error) diag.Diagnostics {
+	diags := diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("execution of command '%s' failed: %s", command, err),
+		Detail:   result.Stdout,
+	}}
+	if result.Stderr != "" {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "stderr output",
+			Detail:   result.Stderr,
+		})
+	}
+	return diags
+}
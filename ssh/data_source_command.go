@@ -0,0 +1,107 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceCommand() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceCommandRead,
+		Schema:      sshCommandSchema(false),
+	}
+}
+
+func sshCommandSchema(sensitive bool) map[string]*schema.Schema {
+	return mergeSchema(connectionSchema(), map[string]*schema.Schema{
+		"command": {
+			Description: "The command to run. It should not modify the remote host",
+			Type:        schema.TypeString,
+			Required:    true,
+		},
+		"parse_json": {
+			Description: "Parse stdout as a JSON object into 'result_json'",
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+		},
+		"stdout": {
+			Type:      schema.TypeString,
+			Computed:  true,
+			Sensitive: sensitive,
+		},
+		"stderr": {
+			Type:      schema.TypeString,
+			Computed:  true,
+			Sensitive: sensitive,
+		},
+		"exit_code": {
+			Type:     schema.TypeInt,
+			Computed: true,
+		},
+		"result_json": {
+			Type:      schema.TypeMap,
+			Computed:  true,
+			Sensitive: sensitive,
+			Elem:      &schema.Schema{Type: schema.TypeString},
+		},
+	})
+}
+
+// parseResultJSON parses a JSON object into a map of strings. Values which
+// are not strings are returned in their JSON encoding
+func parseResultJSON(stdout string) (map[string]interface{}, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stdout), &object); err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(object))
+	for k, raw := range object {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		result[k] = value
+	}
+	return result, nil
+}
+
+func dataSourceCommandRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	config := m.(*Config)
+
+	command := d.Get("command").(string)
+
+	sshRetryConfig, err := newSSHRetryConfig(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ssh := newSSHConfig(d)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sshRetryConfig.timeout)
+	defer cancel()
+
+	result, err := runCommand(ctx, command, ssh, sshRetryConfig, config)
+	if err != nil {
+		return diagFromCommandError(command, result, err)
+	}
+	if d.Get("parse_json").(bool) {
+		resultJSON, err := parseResultJSON(result.Stdout)
+		if err != nil {
+			return diag.FromErr(fmt.Errorf("parsing stdout of command '%s' as JSON object: %w", command, err))
+		}
+		_ = d.Set("result_json", resultJSON)
+	}
+
+	_ = d.Set("stdout", result.Stdout)
+	_ = d.Set("stderr", result.Stderr)
+	_ = d.Set("exit_code", result.ExitCode)
+
+	d.SetId(fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s", d.Get("host").(string), d.Get("port").(int), command)))))
+	return diags
+}
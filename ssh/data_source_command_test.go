@@ -0,0 +1,26 @@
+package ssh
+
+import (
+	"testing"
+)
+
+func TestParseResultJSON(t *testing.T) {
+	result, err := parseResultJSON(`{"token":"abc","port":6443,"nodes":["a","b"]}`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := map[string]string{
+		"token": "abc",
+		"port":  "6443",
+		"nodes": `["a","b"]`,
+	}
+	for k, v := range expected {
+		if result[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, result[k])
+		}
+	}
+
+	if _, err := parseResultJSON("not json"); err == nil {
+		t.Errorf("expected error on invalid JSON")
+	}
+}
@@ -0,0 +1,175 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/loafoe/easyssh-proxy/v2"
+)
+
+func dataSourceFile() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceFileRead,
+		Schema: mergeSchema(connectionSchema(), map[string]*schema.Schema{
+			"path": {
+				Description: "The path of the remote file",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"content": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"content_base64": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"sha256": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"size": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"mode": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"owner": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"group": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"mtime": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		}),
+	}
+}
+
+// remoteFile holds the content and metadata of a file read from a remote host
+type remoteFile struct {
+	Content []byte
+	Size    int64
+	Mode    string
+	Owner   string
+	Group   string
+	ModTime time.Time
+}
+
+// fileNotFoundExitCode is the exit code of readFileCommand when the file does not exist
+const fileNotFoundExitCode = 44
+
+// readFileCommand prints size, octal mode, owner, group and modification time of path on
+// the first line followed by the base64 encoded content. Symlinks are followed
+func readFileCommand(path string) string {
+	return fmt.Sprintf("[ -e %[1]s ] || exit %[2]d; stat -L -c '%%s %%a %%U %%G %%Y' %[1]s && base64 %[1]s", shellQuote(path), fileNotFoundExitCode)
+}
+
+func parseReadFileOutput(output string) (*remoteFile, error) {
+	header, encoded, _ := strings.Cut(output, "\n")
+	fields := strings.Fields(header)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("unexpected stat output: %q", header)
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing size: %w", err)
+	}
+	mode, err := strconv.ParseUint(fields[1], 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("parsing mode: %w", err)
+	}
+	mtime, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing mtime: %w", err)
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(encoded, "\n", ""))
+	if err != nil {
+		return nil, fmt.Errorf("decoding content: %w", err)
+	}
+	return &remoteFile{
+		Content: content,
+		Size:    size,
+		Mode:    fmt.Sprintf("%04o", mode),
+		Owner:   fields[2],
+		Group:   fields[3],
+		ModTime: time.Unix(mtime, 0).UTC(),
+	}, nil
+}
+
+// readRemoteFile reads the content and metadata of path on the remote host.
+// A nil file is returned when path does not exist
+func readRemoteFile(ctx context.Context, path string, ssh *easyssh.MakeConfig, sshRetryConfig SSHRetryConfig, config *Config) (*remoteFile, diag.Diagnostics) {
+	command := readFileCommand(path)
+	result, err := runCommand(ctx, command, ssh, sshRetryConfig, config)
+	if err != nil {
+		return nil, diagFromCommandError(command, result, err)
+	}
+	if result.ExitCode == fileNotFoundExitCode {
+		return nil, nil
+	}
+	if result.ExitCode != 0 {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("reading remote file %s failed with exit code %d", path, result.ExitCode),
+			Detail:   result.Stderr,
+		}}
+	}
+	file, err := parseReadFileOutput(result.Stdout)
+	if err != nil {
+		return nil, diag.FromErr(fmt.Errorf("reading remote file %s: %w", path, err))
+	}
+	return file, nil
+}
+
+func dataSourceFileRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	path := d.Get("path").(string)
+
+	sshRetryConfig, err := newSSHRetryConfig(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ssh := newSSHConfig(d)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sshRetryConfig.timeout)
+	defer cancel()
+
+	file, diags := readRemoteFile(ctx, path, ssh, sshRetryConfig, config)
+	if hasErrors(diags) {
+		return diags
+	}
+	if file == nil {
+		return diag.FromErr(fmt.Errorf("remote file %s does not exist", path))
+	}
+	sum := sha256.Sum256(file.Content)
+
+	_ = d.Set("content", string(file.Content))
+	_ = d.Set("content_base64", base64.StdEncoding.EncodeToString(file.Content))
+	_ = d.Set("sha256", hex.EncodeToString(sum[:]))
+	_ = d.Set("size", int(file.Size))
+	_ = d.Set("mode", file.Mode)
+	_ = d.Set("owner", file.Owner)
+	_ = d.Set("group", file.Group)
+	_ = d.Set("mtime", file.ModTime.Format(time.RFC3339))
+
+	d.SetId(fmt.Sprintf("%s:%d:%s", d.Get("host").(string), d.Get("port").(int), path))
+	return diags
+}
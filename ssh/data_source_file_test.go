@@ -0,0 +1,38 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestParseReadFileOutput(t *testing.T) {
+	file, err := parseReadFileOutput("11 600 root wheel 1700000000\naGVsbG8gd29y\nbGQK\n")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(file.Content) != "hello world\n" {
+		t.Errorf("unexpected content: %q", file.Content)
+	}
+	if file.Mode != "0600" || file.Owner != "root" || file.Group != "wheel" || file.Size != 11 || file.ModTime.Unix() != 1700000000 {
+		t.Errorf("unexpected metadata: %+v", file)
+	}
+
+	if _, err := parseReadFileOutput("stat: cannot stat\n"); err == nil {
+		t.Errorf("expected error on malformed output")
+	}
+}
+
+func TestNewSSHConfig_dataSource(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceFile().Schema, map[string]interface{}{
+		"host":        "remote.host",
+		"user":        "alpine",
+		"private_key": "key",
+		"path":        "/etc/hostname",
+	})
+
+	ssh := newSSHConfig(d)
+	if ssh.User != "alpine" || ssh.Key != "key" || ssh.Port != "22" {
+		t.Errorf("unexpected config: %+v", ssh)
+	}
+}
@@ -0,0 +1,180 @@
+package ssh
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+//go:embed scripts/host_facts.sh
+var hostFactsScript string
+
+func dataSourceHostFacts() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceHostFactsRead,
+		Schema: mergeSchema(connectionSchema(), map[string]*schema.Schema{
+			"facts": {
+				Description: "The facts gathered from the host, a list with a single element",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"os_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"os_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"os_version": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"kernel": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"architecture": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"cpu_count": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"memory_mb": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"init_system": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"package_manager": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"ip_addresses": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+		}),
+	}
+}
+
+type hostFacts struct {
+	OSID           string
+	OSName         string
+	OSVersion      string
+	Kernel         string
+	Architecture   string
+	CPUCount       int
+	MemoryMB       int
+	InitSystem     string
+	PackageManager string
+	IPAddresses    []string
+}
+
+// flatten returns the facts as the single element of the facts attribute
+func (f *hostFacts) flatten() []interface{} {
+	return []interface{}{map[string]interface{}{
+		"os_id":           f.OSID,
+		"os_name":         f.OSName,
+		"os_version":      f.OSVersion,
+		"kernel":          f.Kernel,
+		"architecture":    f.Architecture,
+		"cpu_count":       f.CPUCount,
+		"memory_mb":       f.MemoryMB,
+		"init_system":     f.InitSystem,
+		"package_manager": f.PackageManager,
+		"ip_addresses":    f.IPAddresses,
+	}}
+}
+
+// parseHostFacts parses the key=value output of the host facts probe
+func parseHostFacts(output string) (*hostFacts, error) {
+	facts := &hostFacts{IPAddresses: []string{}}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		var err error
+		switch key {
+		case "os_id":
+			facts.OSID = value
+		case "os_name":
+			facts.OSName = value
+		case "os_version":
+			facts.OSVersion = value
+		case "kernel":
+			facts.Kernel = value
+		case "architecture":
+			facts.Architecture = value
+		case "cpu_count":
+			facts.CPUCount, err = strconv.Atoi(value)
+		case "memory_mb":
+			facts.MemoryMB, err = strconv.Atoi(value)
+		case "init_system":
+			facts.InitSystem = value
+		case "package_manager":
+			facts.PackageManager = value
+		case "ip_address":
+			facts.IPAddresses = append(facts.IPAddresses, value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", key, err)
+		}
+	}
+	if facts.Kernel == "" {
+		return nil, fmt.Errorf("unexpected probe output: %q", output)
+	}
+	return facts, nil
+}
+
+func dataSourceHostFactsRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	config := m.(*Config)
+
+	sshRetryConfig, err := newSSHRetryConfig(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ssh := newSSHConfig(d)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sshRetryConfig.timeout)
+	defer cancel()
+
+	command := "sh -c " + shellQuote(hostFactsScript)
+	result, err := runCommand(ctx, command, ssh, sshRetryConfig, config)
+	if err != nil {
+		return diagFromCommandError("host facts probe", result, err)
+	}
+	if result.ExitCode != 0 {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("host facts probe failed with exit code %d", result.ExitCode),
+			Detail:   result.Stderr,
+		}}
+	}
+	facts, err := parseHostFacts(result.Stdout)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("facts", facts.flatten()); err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId(fmt.Sprintf("%s:%d", d.Get("host").(string), d.Get("port").(int)))
+	return diags
+}
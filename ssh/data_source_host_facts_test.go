@@ -0,0 +1,47 @@
+package ssh
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestParseHostFacts(t *testing.T) {
+	facts, err := parseHostFacts("os_id=alpine\nos_name=Alpine Linux\nos_version=3.20.3\nkernel=6.6.1\narchitecture=aarch64\ncpu_count=4\nmemory_mb=7936\ninit_system=openrc\npackage_manager=apk\nip_address=10.0.0.2\nip_address=fd00::2\n")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if facts.OSName != "Alpine Linux" || facts.CPUCount != 4 || facts.MemoryMB != 7936 || facts.PackageManager != "apk" {
+		t.Errorf("unexpected facts: %+v", facts)
+	}
+	if len(facts.IPAddresses) != 2 || facts.IPAddresses[1] != "fd00::2" {
+		t.Errorf("unexpected ip addresses: %v", facts.IPAddresses)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceHostFacts().Schema, map[string]interface{}{})
+	if err := d.Set("facts", facts.flatten()); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if d.Get("facts.0.cpu_count").(int) != 4 || d.Get("facts.0.ip_addresses.1").(string) != "fd00::2" {
+		t.Errorf("unexpected facts attribute: %v", d.Get("facts"))
+	}
+
+	if _, err := parseHostFacts("fish: Unknown command\n"); err == nil {
+		t.Errorf("expected error on unexpected output")
+	}
+}
+
+func TestHostFactsScript(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh available")
+	}
+	output, err := exec.Command(sh, "-c", hostFactsScript).Output()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := parseHostFacts(string(output)); err != nil {
+		t.Errorf("err: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/loafoe/easyssh-proxy/v2"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// hostKeyAlgorithms are the host key algorithms probed for, in order. For RSA keys
+// rsa-sha2-512 is used so servers which disabled ssh-rsa signatures still offer the key
+var hostKeyAlgorithms = []string{
+	gossh.KeyAlgoED25519,
+	gossh.KeyAlgoECDSA256,
+	gossh.KeyAlgoECDSA384,
+	gossh.KeyAlgoECDSA521,
+	gossh.KeyAlgoRSASHA512,
+}
+
+// errHostKeyCaptured aborts the handshake once the host key is known
+var errHostKeyCaptured = errors.New("host key captured")
+
+func dataSourceHostKey() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceHostKeyRead,
+		Schema: map[string]*schema.Schema{
+			"host": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"port": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      22,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			"bastion_host": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"bastion_port": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      22,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			"bastion_user": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"bastion_password": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"bastion_private_key": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"timeout": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "30s",
+			},
+			"keys": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"authorized_key": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"known_hosts_line": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"fingerprint_sha256": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"fingerprint_md5": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// fetchHostKey performs a key exchange restricted to algorithm and returns the host
+// key offered by the server. No authentication is attempted
+func fetchHostKey(dial func() (net.Conn, error), address, algorithm string, timeout time.Duration) (gossh.PublicKey, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	var hostKey gossh.PublicKey
+	clientConfig := &gossh.ClientConfig{
+		User:              "terraform-provider-ssh",
+		HostKeyAlgorithms: []string{algorithm},
+		Timeout:           timeout,
+		HostKeyCallback: func(_ string, _ net.Addr, key gossh.PublicKey) error {
+			hostKey = key
+			return errHostKeyCaptured
+		},
+	}
+	_, _, _, err = gossh.NewClientConn(conn, address, clientConfig)
+	if hostKey != nil {
+		return hostKey, nil
+	}
+	return nil, err
+}
+
+// hostKeyAttributes returns the attributes of key, the host key of host on port
+func hostKeyAttributes(host string, port int, key gossh.PublicKey) map[string]interface{} {
+	return map[string]interface{}{
+		"type":               key.Type(),
+		"authorized_key":     strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key))),
+		"known_hosts_line":   knownHostsLine(host, int64(port), key),
+		"fingerprint_sha256": gossh.FingerprintSHA256(key),
+		"fingerprint_md5":    gossh.FingerprintLegacyMD5(key),
+	}
+}
+
+func dataSourceHostKeyRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	config := m.(*Config)
+
+	host := normalizeHost(d.Get("host").(string))
+	port := strconv.Itoa(d.Get("port").(int))
+	bastionHost := normalizeHost(d.Get("bastion_host").(string))
+
+	timeout, err := time.ParseDuration(d.Get("timeout").(string))
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("timeout value: %w", err))
+	}
+	address := net.JoinHostPort(host, port)
+
+	dial := func() (net.Conn, error) {
+		return net.DialTimeout("tcp", address, timeout)
+	}
+	if bastionHost != "" {
+		if d.Get("bastion_user").(string) == "" {
+			return diag.FromErr(fmt.Errorf("bastion_user must be set when 'bastion_host' is specified"))
+		}
+		bastionPrivateKeyPassphrase, _ := schema.EnvDefaultFunc("SSH_BASTION_PRIVATE_KEY_PASSPHRASE", "")()
+		bastion := &easyssh.MakeConfig{
+			User:       d.Get("bastion_user").(string),
+			Server:     bastionHost,
+			Port:       strconv.Itoa(d.Get("bastion_port").(int)),
+			Key:        d.Get("bastion_private_key").(string),
+			Passphrase: bastionPrivateKeyPassphrase.(string),
+			Password:   d.Get("bastion_password").(string),
+			Timeout:    timeout,
+			Proxy:      http.ProxyFromEnvironment,
+		}
+		session, client, err := bastion.Connect()
+		if err != nil {
+			return diag.FromErr(fmt.Errorf("connecting to bastion %s: %w", bastionHost, err))
+		}
+		defer client.Close()
+		_ = session.Close()
+		dial = func() (net.Conn, error) {
+			return client.Dial("tcp", address)
+		}
+	}
+
+	keys := make([]interface{}, 0)
+	var lastErr error
+	for _, algorithm := range hostKeyAlgorithms {
+		key, err := fetchHostKey(dial, address, algorithm, timeout)
+		if err != nil {
+			_, _ = config.Debug("host key %s of %s: %v\n", algorithm, address, err)
+			lastErr = err
+			continue
+		}
+		keys = append(keys, hostKeyAttributes(host, d.Get("port").(int), key))
+	}
+	if len(keys) == 0 {
+		return diag.FromErr(fmt.Errorf("no host keys offered by %s: %w", address, lastErr))
+	}
+	_ = d.Set("keys", keys)
+
+	d.SetId(address)
+	return diags
+}
@@ -0,0 +1,92 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestHostKeyAttributes(t *testing.T) {
+	_, publicKey := testKeyPair(t)
+	key, err := parseKey(publicKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, c := range []struct {
+		host     string
+		port     int
+		expected string
+	}{
+		{"example.com", 22, "example.com " + publicKey},
+		{"2001:db8::1", 22, "2001:db8::1 " + publicKey},
+		{"2001:db8::1", 2222, "[2001:db8::1]:2222 " + publicKey},
+	} {
+		line := hostKeyAttributes(c.host, c.port, key)["known_hosts_line"]
+		if line != c.expected {
+			t.Errorf("%s:%d: got %s, expected %s", c.host, c.port, line, c.expected)
+		}
+		// The data source and provider::ssh::known_hosts_line write the same line
+		result, err := runFunction(t, newKnownHostsLineFunction(), types.StringUnknown(),
+			types.StringValue(c.host), types.Int64Value(int64(c.port)), types.StringValue(publicKey))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if result.(types.String).ValueString() != line {
+			t.Errorf("%s:%d: function returned %s, data source %s", c.host, c.port, result, line)
+		}
+	}
+}
+
+func TestFetchHostKey(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	signer, err := gossh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	serverConfig := &gossh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _, _, _ = gossh.NewServerConn(conn, serverConfig)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	address := listener.Addr().String()
+	dial := func() (net.Conn, error) {
+		return net.Dial("tcp", address)
+	}
+
+	key, err := fetchHostKey(dial, address, gossh.KeyAlgoED25519, 5*time.Second)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if gossh.FingerprintSHA256(key) != gossh.FingerprintSHA256(signer.PublicKey()) {
+		t.Errorf("unexpected host key %s", gossh.FingerprintSHA256(key))
+	}
+
+	if _, err := fetchHostKey(dial, address, gossh.KeyAlgoRSASHA512, 5*time.Second); err == nil {
+		t.Errorf("expected error for host key algorithm not offered")
+	}
+}
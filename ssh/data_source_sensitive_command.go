@@ -0,0 +1,12 @@
+package ssh
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceSensitiveCommand() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceCommandRead,
+		Schema:      sshCommandSchema(true),
+	}
+}
@@ -0,0 +1,370 @@
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const tunnelPrivateKey = "tunnel_id"
+
+var (
+	_ ephemeral.EphemeralResource              = &tunnelEphemeralResource{}
+	_ ephemeral.EphemeralResourceWithClose     = &tunnelEphemeralResource{}
+	_ ephemeral.EphemeralResourceWithConfigure = &tunnelEphemeralResource{}
+)
+
+// tunnels holds the open tunnels by ID until Terraform closes them
+var tunnels = struct {
+	sync.Mutex
+	m map[string]*sshTunnel
+}{m: make(map[string]*sshTunnel)}
+
+type tunnelEphemeralResource struct {
+	config *Config
+}
+
+type tunnelModel struct {
+	Host                        types.String `tfsdk:"host"`
+	Port                        types.Int64  `tfsdk:"port"`
+	User                        types.String `tfsdk:"user"`
+	Password                    types.String `tfsdk:"password"`
+	PrivateKey                  types.String `tfsdk:"private_key"`
+	BastionHost                 types.String `tfsdk:"bastion_host"`
+	BastionPort                 types.Int64  `tfsdk:"bastion_port"`
+	BastionUser                 types.String `tfsdk:"bastion_user"`
+	BastionPassword             types.String `tfsdk:"bastion_password"`
+	BastionPrivateKey           types.String `tfsdk:"bastion_private_key"`
+	PrivateKeyPassphrase        types.String `tfsdk:"private_key_passphrase"`
+	BastionPrivateKeyPassphrase types.String `tfsdk:"bastion_private_key_passphrase"`
+	Timeout                     types.String `tfsdk:"timeout"`
+	RetryDelay                  types.String `tfsdk:"retry_delay"`
+	RemoteHost                  types.String `tfsdk:"remote_host"`
+	RemotePort                  types.Int64  `tfsdk:"remote_port"`
+	LocalHost                   types.String `tfsdk:"local_host"`
+	LocalPort                   types.Int64  `tfsdk:"local_port"`
+}
+
+func newTunnelEphemeralResource() ephemeral.EphemeralResource {
+	return &tunnelEphemeralResource{}
+}
+
+func (r *tunnelEphemeralResource) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_tunnel"
+}
+
+func (r *tunnelEphemeralResource) Schema(_ context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Forwards a local port to a port reachable from the SSH host for the duration of a Terraform run",
+		Attributes: map[string]schema.Attribute{
+			"host": schema.StringAttribute{
+				Required: true,
+			},
+			"port": schema.Int64Attribute{
+				Description: "The SSH port of the host, defaults to 22",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.Between(1, 65535),
+				},
+			},
+			"user": schema.StringAttribute{
+				Required: true,
+			},
+			"password": schema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+			},
+			"private_key": schema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+			},
+			"bastion_host": schema.StringAttribute{
+				Optional: true,
+			},
+			"bastion_port": schema.Int64Attribute{
+				Description: "The SSH port of the bastion host, defaults to 22",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.Between(1, 65535),
+				},
+			},
+			"bastion_user": schema.StringAttribute{
+				Optional: true,
+			},
+			"bastion_password": schema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+			},
+			"bastion_private_key": schema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+			},
+			"private_key_passphrase": schema.StringAttribute{
+				Description: "The passphrase of the private key, defaults to SSH_PRIVATE_KEY_PASSPHRASE",
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"bastion_private_key_passphrase": schema.StringAttribute{
+				Description: "The passphrase of the bastion private key, defaults to SSH_BASTION_PRIVATE_KEY_PASSPHRASE",
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"timeout": schema.StringAttribute{
+				Description: "How long to keep trying to connect, defaults to 5m",
+				Optional:    true,
+			},
+			"retry_delay": schema.StringAttribute{
+				Description: "The delay between connection attempts, defaults to 10s",
+				Optional:    true,
+			},
+			"remote_host": schema.StringAttribute{
+				Description: "The host connections are forwarded to, as seen from the SSH host",
+				Required:    true,
+			},
+			"remote_port": schema.Int64Attribute{
+				Description: "The port connections are forwarded to",
+				Required:    true,
+				Validators: []validator.Int64{
+					int64validator.Between(1, 65535),
+				},
+			},
+			"local_host": schema.StringAttribute{
+				Description: "The local address to listen on, defaults to 127.0.0.1",
+				Optional:    true,
+				Computed:    true,
+			},
+			"local_port": schema.Int64Attribute{
+				Description: "The local port to listen on, a free port is picked when not set",
+				Optional:    true,
+				Computed:    true,
+				Validators: []validator.Int64{
+					int64validator.Between(1, 65535),
+				},
+			},
+		},
+	}
+}
+
+func (r *tunnelEphemeralResource) Configure(_ context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected provider data", fmt.Sprintf("expected *Config, got %T", req.ProviderData))
+		return
+	}
+	r.config = config
+}
+
+// stringOrDefault returns the value of s, or def when s is null or empty
+func stringOrDefault(s types.String, def string) string {
+	if s.ValueString() == "" {
+		return def
+	}
+	return s.ValueString()
+}
+
+// portOrDefault returns the port p, or 22 when p is null
+func portOrDefault(p types.Int64) string {
+	if p.IsNull() {
+		return "22"
+	}
+	return strconv.FormatInt(p.ValueInt64(), 10)
+}
+
+func (r *tunnelEphemeralResource) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	var data tunnelModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	config := r.config
+	if config == nil {
+		config = &Config{}
+	}
+
+	timeout, err := time.ParseDuration(stringOrDefault(data.Timeout, "5m"))
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("timeout"), "Invalid timeout", err.Error())
+		return
+	}
+	retryDelay, err := time.ParseDuration(stringOrDefault(data.RetryDelay, "10s"))
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("retry_delay"), "Invalid retry_delay", err.Error())
+		return
+	}
+	ssh := connectionSettings{
+		Host:              data.Host.ValueString(),
+		Port:              portOrDefault(data.Port),
+		User:              data.User.ValueString(),
+		Password:          data.Password.ValueString(),
+		PrivateKey:        data.PrivateKey.ValueString(),
+		BastionHost:       data.BastionHost.ValueString(),
+		BastionPort:       portOrDefault(data.BastionPort),
+		BastionUser:       data.BastionUser.ValueString(),
+		BastionPassword:   data.BastionPassword.ValueString(),
+		BastionPrivateKey: data.BastionPrivateKey.ValueString(),
+
+		PrivateKeyPassphrase:        data.PrivateKeyPassphrase.ValueString(),
+		BastionPrivateKeyPassphrase: data.BastionPrivateKeyPassphrase.ValueString(),
+	}.sshConfig()
+
+	connectCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var client *gossh.Client
+	for {
+		var session *gossh.Session
+		session, client, err = ssh.Connect()
+		_, _ = config.Debug("tunnel connect to %s: %v\n", ssh.Server, err)
+		if err == nil {
+			_ = session.Close()
+			break
+		}
+		select {
+		case <-time.After(retryDelay):
+			// Retry
+		case <-connectCtx.Done():
+			resp.Diagnostics.AddError("Connecting to SSH host failed", fmt.Sprintf("%s: %v", connectCtx.Err(), err))
+			return
+		}
+	}
+
+	localHost := stringOrDefault(data.LocalHost, "127.0.0.1")
+	localAddress := net.JoinHostPort(localHost, strconv.FormatInt(data.LocalPort.ValueInt64(), 10))
+	remoteAddress := net.JoinHostPort(data.RemoteHost.ValueString(), strconv.FormatInt(data.RemotePort.ValueInt64(), 10))
+	tunnel, err := newSSHTunnel(client, localAddress, remoteAddress, config)
+	if err != nil {
+		_ = client.Close()
+		resp.Diagnostics.AddError("Opening tunnel failed", err.Error())
+		return
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	tunnelID := hex.EncodeToString(id)
+	tunnels.Lock()
+	tunnels.m[tunnelID] = tunnel
+	tunnels.Unlock()
+
+	encodedID, _ := json.Marshal(tunnelID)
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, tunnelPrivateKey, encodedID)...)
+
+	data.LocalHost = types.StringValue(localHost)
+	data.LocalPort = types.Int64Value(int64(tunnel.localPort()))
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+}
+
+func (r *tunnelEphemeralResource) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	encodedID, diags := req.Private.GetKey(ctx, tunnelPrivateKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() || encodedID == nil {
+		return
+	}
+	var tunnelID string
+	if err := json.Unmarshal(encodedID, &tunnelID); err != nil {
+		resp.Diagnostics.AddError("Invalid tunnel ID", err.Error())
+		return
+	}
+	tunnels.Lock()
+	tunnel, ok := tunnels.m[tunnelID]
+	delete(tunnels.m, tunnelID)
+	tunnels.Unlock()
+	if !ok {
+		return
+	}
+	if err := tunnel.close(); err != nil {
+		resp.Diagnostics.AddWarning("Closing tunnel failed", err.Error())
+	}
+}
+
+// sshTunnel forwards connections accepted on a local listener to a remote address over an SSH client
+type sshTunnel struct {
+	listener      net.Listener
+	client        *gossh.Client
+	remoteAddress string
+	config        *Config
+	wg            sync.WaitGroup
+}
+
+// newSSHTunnel starts listening on localAddress and forwards each connection to remoteAddress
+func newSSHTunnel(client *gossh.Client, localAddress, remoteAddress string, config *Config) (*sshTunnel, error) {
+	listener, err := net.Listen("tcp", localAddress)
+	if err != nil {
+		return nil, err
+	}
+	t := &sshTunnel{
+		listener:      listener,
+		client:        client,
+		remoteAddress: remoteAddress,
+		config:        config,
+	}
+	t.wg.Add(1)
+	go t.serve()
+	return t, nil
+}
+
+func (t *sshTunnel) localPort() int {
+	return t.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (t *sshTunnel) serve() {
+	defer t.wg.Done()
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.wg.Add(1)
+		go t.forward(local)
+	}
+}
+
+func (t *sshTunnel) forward(local net.Conn) {
+	defer t.wg.Done()
+	defer local.Close()
+
+	remote, err := t.client.Dial("tcp", t.remoteAddress)
+	if err != nil {
+		_, _ = t.config.Debug("tunnel dial %s: %v\n", t.remoteAddress, err)
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// close stops accepting connections and closes the SSH client, which ends all forwarded connections
+func (t *sshTunnel) close() error {
+	err := t.listener.Close()
+	if clientErr := t.client.Close(); err == nil {
+		err = clientErr
+	}
+	t.wg.Wait()
+	return err
+}
@@ -0,0 +1,146 @@
+package ssh
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// startForwardingServer starts an SSH server which accepts direct-tcpip channels
+func startForwardingServer(t *testing.T) string {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	signer, err := gossh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	serverConfig := &gossh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, channels, requests, err := gossh.NewServerConn(conn, serverConfig)
+				if err != nil {
+					return
+				}
+				go gossh.DiscardRequests(requests)
+				for newChannel := range channels {
+					if newChannel.ChannelType() != "direct-tcpip" {
+						_ = newChannel.Reject(gossh.UnknownChannelType, "unsupported")
+						continue
+					}
+					var target struct {
+						Host       string
+						Port       uint32
+						OriginHost string
+						OriginPort uint32
+					}
+					if err := gossh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+						_ = newChannel.Reject(gossh.ConnectionFailed, err.Error())
+						continue
+					}
+					remote, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.FormatUint(uint64(target.Port), 10)))
+					if err != nil {
+						_ = newChannel.Reject(gossh.ConnectionFailed, err.Error())
+						continue
+					}
+					channel, channelRequests, err := newChannel.Accept()
+					if err != nil {
+						_ = remote.Close()
+						continue
+					}
+					go gossh.DiscardRequests(channelRequests)
+					go func() {
+						_, _ = io.Copy(channel, remote)
+						_ = channel.Close()
+					}()
+					go func() {
+						_, _ = io.Copy(remote, channel)
+						_ = remote.Close()
+					}()
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestSSHTunnel(t *testing.T) {
+	// Echo server only reachable through the tunnel in a real setup
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	client, err := gossh.Dial("tcp", startForwardingServer(t), &gossh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(), //nolint:gosec
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	tunnel, err := newSSHTunnel(client, "127.0.0.1:0", echo.Addr().String(), &Config{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if tunnel.localPort() == 0 {
+		t.Fatalf("expected a local port to be picked")
+	}
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", tunnel.listener.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if _, err := conn.Write([]byte("ping\n")); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if line != "ping\n" {
+			t.Errorf("unexpected response %q", line)
+		}
+		_ = conn.Close()
+	}
+
+	if err := tunnel.close(); err != nil {
+		t.Errorf("err: %v", err)
+	}
+	if _, err := net.Dial("tcp", tunnel.listener.Addr().String()); err == nil {
+		t.Errorf("expected listener to be closed")
+	}
+}
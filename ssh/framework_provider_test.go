@@ -0,0 +1,30 @@
+package ssh
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+)
+
+func TestProviderServerFactory(t *testing.T) {
+	ctx := context.Background()
+	factory, err := ProviderServerFactory(ctx)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// Muxing fails when the provider schemas of the SDK and framework providers differ
+	resp, err := factory().GetProviderSchema(ctx, &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, d := range resp.Diagnostics {
+		t.Errorf("%s: %s", d.Summary, d.Detail)
+	}
+	if _, ok := resp.EphemeralResourceSchemas["ssh_tunnel"]; !ok {
+		t.Errorf("ssh_tunnel ephemeral resource not served")
+	}
+	if _, ok := resp.ResourceSchemas["ssh_resource"]; !ok {
+		t.Errorf("ssh_resource not served")
+	}
+}
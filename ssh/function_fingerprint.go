@@ -0,0 +1,72 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var _ function.Function = &fingerprintFunction{}
+
+type fingerprintFunction struct{}
+
+func newFingerprintFunction() function.Function {
+	return &fingerprintFunction{}
+}
+
+func (f *fingerprintFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "fingerprint"
+}
+
+func (f *fingerprintFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "SHA256 fingerprint of an SSH key",
+		Description: "Returns the SHA256 fingerprint of a public key in authorized_keys format or of an unencrypted private key, as shown by ssh-keygen -l",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "key",
+				Description: "A public key in authorized_keys format or a private key in PEM format",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *fingerprintFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var key string
+	resp.Error = req.Arguments.Get(ctx, &key)
+	if resp.Error != nil {
+		return
+	}
+	publicKey, err := parseKey(key)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+	resp.Error = resp.Result.Set(ctx, gossh.FingerprintSHA256(publicKey))
+}
+
+// parseKey parses a public key in authorized_keys format, or an unencrypted private key
+// in which case its public key is returned
+func parseKey(key string) (gossh.PublicKey, error) {
+	if publicKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(key)); err == nil {
+		return publicKey, nil
+	}
+	signer, err := parsePrivateKey(key)
+	if err != nil {
+		return nil, errors.New("not a public key in authorized_keys format nor a private key")
+	}
+	return signer.PublicKey(), nil
+}
+
+// parsePrivateKey parses an unencrypted private key
+func parsePrivateKey(key string) (gossh.Signer, error) {
+	signer, err := gossh.ParsePrivateKey([]byte(key))
+	var passphraseErr *gossh.PassphraseMissingError
+	if errors.As(err, &passphraseErr) {
+		return nil, errors.New("encrypted private keys are not supported")
+	}
+	return signer, err
+}
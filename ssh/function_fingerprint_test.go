@@ -0,0 +1,61 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// runFunction runs f with args and returns its result
+func runFunction(t *testing.T, f function.Function, returnType attr.Value, args ...attr.Value) (attr.Value, *function.FuncError) {
+	t.Helper()
+	resp := function.RunResponse{Result: function.NewResultData(returnType)}
+	f.Run(context.Background(), function.RunRequest{Arguments: function.NewArgumentsData(args)}, &resp)
+	return resp.Result.Value(), resp.Error
+}
+
+// testKeyPair returns a new private key in OpenSSH format and its public key in authorized_keys format
+func testKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	block, err := gossh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	publicKey, err := gossh.NewPublicKey(public)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return string(pem.EncodeToMemory(block)), strings.TrimSpace(string(gossh.MarshalAuthorizedKey(publicKey)))
+}
+
+func TestFingerprintFunction(t *testing.T) {
+	privateKey, publicKey := testKeyPair(t)
+	key, _, _, _, _ := gossh.ParseAuthorizedKey([]byte(publicKey))
+	expected := gossh.FingerprintSHA256(key)
+
+	for _, input := range []string{publicKey, publicKey + " user@host", privateKey} {
+		result, err := runFunction(t, newFingerprintFunction(), types.StringUnknown(), types.StringValue(input))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if result.(types.String).ValueString() != expected {
+			t.Errorf("unexpected fingerprint %s", result)
+		}
+	}
+
+	if _, err := runFunction(t, newFingerprintFunction(), types.StringUnknown(), types.StringValue("not a key")); err == nil {
+		t.Errorf("expected error for invalid key")
+	}
+}
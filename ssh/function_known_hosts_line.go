@@ -0,0 +1,79 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var _ function.Function = &knownHostsLineFunction{}
+
+type knownHostsLineFunction struct{}
+
+func newKnownHostsLineFunction() function.Function {
+	return &knownHostsLineFunction{}
+}
+
+func (f *knownHostsLineFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "known_hosts_line"
+}
+
+func (f *knownHostsLineFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "known_hosts line for a host key",
+		Description: "Returns the known_hosts line of a host key. The host is written as [host]:port when the port is not 22",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "host",
+				Description: "The hostname or IP address of the host",
+			},
+			function.Int64Parameter{
+				Name:        "port",
+				Description: "The SSH port of the host",
+			},
+			function.StringParameter{
+				Name:        "key",
+				Description: "The host key in authorized_keys format",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *knownHostsLineFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var host, key string
+	var port int64
+	resp.Error = req.Arguments.Get(ctx, &host, &port, &key)
+	if resp.Error != nil {
+		return
+	}
+	if port < 1 || port > 65535 {
+		resp.Error = function.NewArgumentFuncError(1, "port must be between 1 and 65535")
+		return
+	}
+	publicKey, err := parseKey(key)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(2, err.Error())
+		return
+	}
+	resp.Error = resp.Result.Set(ctx, knownHostsLine(host, port, publicKey))
+}
+
+// knownHostsLine returns the known_hosts line of key for host, as written by OpenSSH
+func knownHostsLine(host string, port int64, key gossh.PublicKey) string {
+	return knownHostsHost(host, port) + " " + strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key)))
+}
+
+// knownHostsHost formats a host like OpenSSH does in known_hosts, the host is written
+// as [host]:port when the port is not 22. Unlike knownhosts.Normalize IPv6 addresses on
+// port 22 are not enclosed in brackets, which is why knownhosts.Line is not used
+func knownHostsHost(host string, port int64) string {
+	host = normalizeHost(host)
+	if port == 22 {
+		return host
+	}
+	return fmt.Sprintf("[%s]:%d", host, port)
+}
@@ -0,0 +1,38 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestKnownHostsLineFunction(t *testing.T) {
+	_, publicKey := testKeyPair(t)
+
+	for _, c := range []struct {
+		host     string
+		port     int64
+		expected string
+	}{
+		{"example.com", 22, "example.com " + publicKey},
+		{"example.com", 2222, "[example.com]:2222 " + publicKey},
+		{"2001:db8::1", 22, "2001:db8::1 " + publicKey},
+		{"[2001:db8::1]", 22, "2001:db8::1 " + publicKey},
+		{"2001:db8::1", 2222, "[2001:db8::1]:2222 " + publicKey},
+	} {
+		result, err := runFunction(t, newKnownHostsLineFunction(), types.StringUnknown(),
+			types.StringValue(c.host), types.Int64Value(c.port), types.StringValue(publicKey))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if result.(types.String).ValueString() != c.expected {
+			t.Errorf("%s:%d: got %s, expected %s", c.host, c.port, result, c.expected)
+		}
+	}
+
+	_, err := runFunction(t, newKnownHostsLineFunction(), types.StringUnknown(),
+		types.StringValue("example.com"), types.Int64Value(0), types.StringValue(publicKey))
+	if err == nil {
+		t.Errorf("expected error for invalid port")
+	}
+}
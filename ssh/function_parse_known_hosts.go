@@ -0,0 +1,99 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var _ function.Function = &parseKnownHostsFunction{}
+
+type parseKnownHostsFunction struct{}
+
+// knownHostModel is a single entry of a known_hosts file
+type knownHostModel struct {
+	Marker            string   `tfsdk:"marker"`
+	Hosts             []string `tfsdk:"hosts"`
+	Type              string   `tfsdk:"type"`
+	Key               string   `tfsdk:"key"`
+	FingerprintSHA256 string   `tfsdk:"fingerprint_sha256"`
+	Comment           string   `tfsdk:"comment"`
+}
+
+var knownHostAttrTypes = map[string]attr.Type{
+	"marker":             types.StringType,
+	"hosts":              types.ListType{ElemType: types.StringType},
+	"type":               types.StringType,
+	"key":                types.StringType,
+	"fingerprint_sha256": types.StringType,
+	"comment":            types.StringType,
+}
+
+func newParseKnownHostsFunction() function.Function {
+	return &parseKnownHostsFunction{}
+}
+
+func (f *parseKnownHostsFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "parse_known_hosts"
+}
+
+func (f *parseKnownHostsFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary: "Parse the content of a known_hosts file",
+		Description: "Returns the entries of a known_hosts file with their marker (cert-authority or revoked), hosts, key type, " +
+			"key in authorized_keys format, SHA256 fingerprint and comment. Hashed hosts are returned as is",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "content",
+				Description: "The content of a known_hosts file",
+			},
+		},
+		Return: function.ListReturn{
+			ElementType: types.ObjectType{AttrTypes: knownHostAttrTypes},
+		},
+	}
+}
+
+func (f *parseKnownHostsFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var content string
+	resp.Error = req.Arguments.Get(ctx, &content)
+	if resp.Error != nil {
+		return
+	}
+	entries, err := parseKnownHosts(content)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+	resp.Error = resp.Result.Set(ctx, entries)
+}
+
+// parseKnownHosts parses all entries of a known_hosts file, skipping comments and empty lines
+func parseKnownHosts(content string) ([]knownHostModel, error) {
+	entries := make([]knownHostModel, 0)
+	rest := []byte(content)
+	for {
+		marker, hosts, key, comment, next, err := gossh.ParseKnownHosts(rest)
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, knownHostModel{
+			Marker:            marker,
+			Hosts:             hosts,
+			Type:              key.Type(),
+			Key:               strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key))),
+			FingerprintSHA256: gossh.FingerprintSHA256(key),
+			Comment:           comment,
+		})
+		rest = next
+	}
+}
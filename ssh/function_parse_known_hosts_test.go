@@ -0,0 +1,44 @@
+package ssh
+
+import (
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestParseKnownHosts(t *testing.T) {
+	_, publicKey := testKeyPair(t)
+	key, _, _, _, _ := gossh.ParseAuthorizedKey([]byte(publicKey))
+
+	content := "# comment\n\n" +
+		"example.com,10.0.0.1 " + publicKey + " web\n" +
+		"@cert-authority *.example.com " + publicKey + "\n" +
+		"|1|c2FsdA==|aGFzaA== " + publicKey + "\n"
+	entries, err := parseKnownHosts(content)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	first := entries[0]
+	if len(first.Hosts) != 2 || first.Hosts[0] != "example.com" || first.Hosts[1] != "10.0.0.1" {
+		t.Errorf("unexpected hosts %v", first.Hosts)
+	}
+	if first.Comment != "web" || first.Marker != "" || first.Key != publicKey || first.Type != key.Type() {
+		t.Errorf("unexpected entry %+v", first)
+	}
+	if first.FingerprintSHA256 != gossh.FingerprintSHA256(key) {
+		t.Errorf("unexpected fingerprint %s", first.FingerprintSHA256)
+	}
+	if entries[1].Marker != "cert-authority" {
+		t.Errorf("unexpected marker %q", entries[1].Marker)
+	}
+	if entries[2].Hosts[0] != "|1|c2FsdA==|aGFzaA==" {
+		t.Errorf("expected hashed host to be kept, got %v", entries[2].Hosts)
+	}
+
+	if _, err := parseKnownHosts("example.com not-a-key\n"); err == nil {
+		t.Errorf("expected error for invalid line")
+	}
+}
@@ -0,0 +1,49 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var _ function.Function = &publicKeyFunction{}
+
+type publicKeyFunction struct{}
+
+func newPublicKeyFunction() function.Function {
+	return &publicKeyFunction{}
+}
+
+func (f *publicKeyFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "public_key"
+}
+
+func (f *publicKeyFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Public key of an SSH private key",
+		Description: "Returns the public key of an unencrypted private key in authorized_keys format, without a trailing newline",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "private_key",
+				Description: "A private key in PEM or OpenSSH format",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *publicKeyFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var privateKey string
+	resp.Error = req.Arguments.Get(ctx, &privateKey)
+	if resp.Error != nil {
+		return
+	}
+	signer, err := parsePrivateKey(privateKey)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+	resp.Error = resp.Result.Set(ctx, strings.TrimSpace(string(gossh.MarshalAuthorizedKey(signer.PublicKey()))))
+}
@@ -0,0 +1,30 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestPublicKeyFunction(t *testing.T) {
+	privateKey, publicKey := testKeyPair(t)
+
+	result, err := runFunction(t, newPublicKeyFunction(), types.StringUnknown(), types.StringValue(privateKey))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if result.(types.String).ValueString() != publicKey {
+		t.Errorf("unexpected public key %s, expected %s", result, publicKey)
+	}
+
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	block, _ := gossh.MarshalPrivateKeyWithPassphrase(private, "", []byte("secret"))
+	_, err = runFunction(t, newPublicKeyFunction(), types.StringUnknown(), types.StringValue(string(pem.EncodeToMemory(block))))
+	if err == nil || err.Text != "encrypted private keys are not supported" {
+		t.Errorf("expected error for encrypted key, got %v", err)
+	}
+}
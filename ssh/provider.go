@@ -2,7 +2,6 @@ package ssh
 
 import (
 	"context"
-	"os"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -24,29 +23,24 @@ func Provider() *schema.Provider {
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"ssh_resource":           resourceResource(),
-			"ssh_sensitive_resource": sensitiveResourceResource(),
+			"ssh_file":           resourceFile(),
+			"ssh_authorized_key": resourceAuthorizedKey(),
+			"ssh_file_line":      resourceFileLine(),
+			"ssh_file_block":     resourceFileBlock(),
+		},
+		DataSourcesMap: map[string]*schema.Resource{
+			"ssh_file":              dataSourceFile(),
+			"ssh_command":           dataSourceCommand(),
+			"ssh_sensitive_command": dataSourceSensitiveCommand(),
+			"ssh_host_facts":        dataSourceHostFacts(),
+			"ssh_host_key":          dataSourceHostKey(),
 		},
-		DataSourcesMap:       map[string]*schema.Resource{},
 		ConfigureContextFunc: providerConfigure,
 	}
 }
 
 func providerConfigure(_ context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
-	config := &Config{}
-
 	var diags diag.Diagnostics
 
-	config.DebugLog = d.Get("debug_log").(string)
-
-	if config.DebugLog != "" {
-		debugFile, err := os.OpenFile(config.DebugLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-		if err != nil {
-			config.debugFile = nil
-		} else {
-			config.debugFile = debugFile
-		}
-	}
-
-	return config, diags
+	return newConfig(d.Get("debug_log").(string)), diags
 }
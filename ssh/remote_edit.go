@@ -0,0 +1,197 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/loafoe/easyssh-proxy/v2"
+)
+
+// splitLines splits content into lines, a trailing newline does not produce an empty last line
+func splitLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// joinLines joins lines into content terminated by a newline
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// remoteEditor reads and atomically rewrites a single remote file
+type remoteEditor struct {
+	ssh              *easyssh.MakeConfig
+	sshRetryConfig   SSHRetryConfig
+	transferProtocol string
+	config           *Config
+	path             string
+}
+
+func newRemoteEditor(d *schema.ResourceData, m interface{}) (*remoteEditor, error) {
+	sshRetryConfig, err := newSSHRetryConfig(d)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteEditor{
+		ssh:              newSSHConfig(d),
+		sshRetryConfig:   sshRetryConfig,
+		transferProtocol: d.Get("transfer_protocol").(string),
+		config:           m.(*Config),
+		path:             d.Get("path").(string),
+	}, nil
+}
+
+// infallible returns edit as an edit which does not fail
+func infallible(edit func(string) string) func(string) (string, error) {
+	return func(content string) (string, error) {
+		return edit(content), nil
+	}
+}
+
+// read returns the content of the remote file, which is empty when the file does not exist
+func (e *remoteEditor) read() (string, diag.Diagnostics) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.sshRetryConfig.timeout)
+	defer cancel()
+
+	file, diags := readRemoteFile(ctx, e.path, e.ssh, e.sshRetryConfig, e.config)
+	if hasErrors(diags) || file == nil {
+		return "", diags
+	}
+	return string(file.Content), diags
+}
+
+// inSync reports whether applying edit to the remote file would not change it.
+// A missing file is in sync when edit would leave it empty
+func (e *remoteEditor) inSync(edit func(string) (string, error)) (bool, diag.Diagnostics) {
+	content, diags := e.read()
+	if hasErrors(diags) {
+		return false, diags
+	}
+	newContent, err := edit(content)
+	if err != nil {
+		return false, append(diags, diag.FromErr(fmt.Errorf("editing %s: %w", e.path, err))...)
+	}
+	return newContent == content, diags
+}
+
+// apply reads the remote file, applies edit and writes the result back when it changed.
+// The new content is written to a temporary file next to it which then replaces the
+// original, keeping its mode and ownership. The edit fails when the ownership can not be kept
+// and the file is not owned by the connecting user. Symlinks are followed so the file they point
+// to is replaced rather than the link. A missing file is only created when create is set
+func (e *remoteEditor) apply(create bool, edit func(string) (string, error)) diag.Diagnostics {
+	ctx, cancel := context.WithTimeout(context.Background(), e.sshRetryConfig.timeout)
+	defer cancel()
+
+	file, diags := readRemoteFile(ctx, e.path, e.ssh, e.sshRetryConfig, e.config)
+	if hasErrors(diags) {
+		return diags
+	}
+	var content string
+	if file != nil {
+		content = string(file.Content)
+	}
+	newContent, err := edit(content)
+	if err != nil {
+		return append(diags, diag.FromErr(fmt.Errorf("editing %s: %w", e.path, err))...)
+	}
+	if file != nil && newContent == content {
+		return diags
+	}
+	if file == nil {
+		if newContent == "" {
+			return diags
+		}
+		if !create {
+			return diag.FromErr(fmt.Errorf("remote file %s does not exist", e.path))
+		}
+	}
+
+	target := e.path
+	if file != nil {
+		resolved, resolveDiags := e.resolve(ctx)
+		if hasErrors(resolveDiags) {
+			return append(diags, resolveDiags...)
+		}
+		target = resolved
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	tmp := fmt.Sprintf("%s.terraform-%s", target, hex.EncodeToString(suffix))
+
+	mode := "0644"
+	if file != nil {
+		mode = file.Mode
+	}
+	buffer := bytes.NewBufferString(newContent)
+	if err := copyWithRetry(ctx, e.sshRetryConfig.retryDelay, func() error {
+		return writeFile(e.ssh, e.transferProtocol, bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), tmp, fileMode(mode), time.Time{})
+	}); err != nil {
+		return diag.FromErr(fmt.Errorf("writing %s: %w", tmp, err))
+	}
+	command := fmt.Sprintf("chmod %s %s", mode, shellQuote(tmp))
+	if file != nil {
+		// Only the owner may keep the file when it can not be given back, e.g. an unprivileged user whose
+		// group differs. Anyone else would take over the file of another user
+		command += fmt.Sprintf(" && { chown %s %s || [ \"$(id -un)\" = %s ]; }", shellQuote(file.Owner+":"+file.Group), shellQuote(tmp), shellQuote(file.Owner))
+	}
+	command += fmt.Sprintf(" && mv -f %s %s || { rm -f %s; exit 1; }", shellQuote(tmp), shellQuote(target), shellQuote(tmp))
+	result, err := runCommand(ctx, command, e.ssh, e.sshRetryConfig, e.config)
+	if err != nil {
+		return diagFromCommandError(command, result, err)
+	}
+	if result.ExitCode != 0 {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("replacing remote file %s failed with exit code %d", e.path, result.ExitCode),
+			Detail:   result.Stderr,
+		}}
+	}
+	return diags
+}
+
+// resolve returns the canonical path of the existing remote file, following symlinks
+func (e *remoteEditor) resolve(ctx context.Context) (string, diag.Diagnostics) {
+	command := fmt.Sprintf("readlink -f %s", shellQuote(e.path))
+	result, err := runCommand(ctx, command, e.ssh, e.sshRetryConfig, e.config)
+	if err != nil {
+		return "", diagFromCommandError(command, result, err)
+	}
+	resolved := strings.TrimSuffix(result.Stdout, "\n")
+	if result.ExitCode != 0 || resolved == "" {
+		return "", diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("resolving remote file %s failed with exit code %d", e.path, result.ExitCode),
+			Detail:   result.Stderr,
+		}}
+	}
+	return resolved, nil
+}
+
+// copyWithRetry retries write until it succeeds or ctx is done
+func copyWithRetry(ctx context.Context, retryDelay time.Duration, write func() error) error {
+	for {
+		err := write()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-time.After(retryDelay):
+			// Retry
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", ctx.Err(), err)
+		}
+	}
+}
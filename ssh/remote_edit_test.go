@@ -0,0 +1,116 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+)
+
+func TestRemoteEditorApply_symlink(t *testing.T) {
+	s := sshtest.NewServer(t)
+	target := s.Path("resolv.conf.real")
+	link := s.Path("resolv.conf")
+	if err := os.WriteFile(target, []byte("nameserver 10.0.0.1\n"), 0640); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	e := &remoteEditor{
+		ssh:              testServerSSHConfig(s),
+		sshRetryConfig:   SSHRetryConfig{retryDelay: testRetryDelay, timeout: testTimeout},
+		transferProtocol: TransferProtocolSCP,
+		config:           newConfig(os.DevNull),
+		path:             link,
+	}
+
+	diags := e.apply(false, infallible(func(content string) string {
+		return content + "nameserver 10.0.0.2\n"
+	}))
+	if hasErrors(diags) {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected %s to remain a symlink, got mode %s", link, info.Mode())
+	}
+	info, err = os.Stat(target)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0640 {
+		t.Errorf("expected the target to keep mode 0640, got %o", mode)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "nameserver 10.0.0.1\nnameserver 10.0.0.2\n" {
+		t.Errorf("unexpected target content: %q", content)
+	}
+}
+
+func TestRemoteEditorApply_chown(t *testing.T) {
+	// chown fails as it would for an unprivileged user
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "chown"), []byte("#!/bin/sh\necho \"chown: operation not permitted\" >&2\nexit 1\n"), 0700); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	cases := map[string]struct {
+		otherOwner bool
+		wantError  bool
+	}{
+		"owned by the connecting user": {},
+		"owned by another user":        {otherOwner: true, wantError: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if c.otherOwner && os.Geteuid() != 0 {
+				t.Skip("giving a file to another user requires root")
+			}
+			s := sshtest.NewServer(t)
+			path := s.Path("hosts")
+			content := "127.0.0.1 localhost\n"
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if c.otherOwner {
+				if err := os.Chown(path, 65534, 65534); err != nil {
+					t.Fatalf("err: %v", err)
+				}
+			}
+			e := &remoteEditor{
+				ssh:              testServerSSHConfig(s),
+				sshRetryConfig:   SSHRetryConfig{retryDelay: testRetryDelay, timeout: testTimeout},
+				transferProtocol: TransferProtocolSCP,
+				config:           newConfig(os.DevNull),
+				path:             path,
+			}
+
+			diags := e.apply(false, infallible(func(content string) string {
+				return content + "10.0.0.5 db\n"
+			}))
+			if got := hasErrors(diags); got != c.wantError {
+				t.Fatalf("expected error %t, got %v", c.wantError, diags)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			want := content + "10.0.0.5 db\n"
+			if c.wantError {
+				want = content
+			}
+			if string(got) != want {
+				t.Errorf("expected content %q, got %q", want, got)
+			}
+			if matches, _ := filepath.Glob(path + ".terraform-*"); len(matches) > 0 {
+				t.Errorf("expected the temporary file to be removed, found %v", matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package ssh
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// lockTimeoutExitCode is the exit code of remote edits which could not acquire the lock
+const lockTimeoutExitCode = 75
+
+var usernameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_.-]*\$?$`)
+
+func resourceAuthorizedKey() *schema.Resource {
+	s := mergeSchema(connectionSchema(), map[string]*schema.Schema{
+		"key": {
+			Description:  "The public key in authorized_keys format",
+			Type:         schema.TypeString,
+			Required:     true,
+			ForceNew:     true,
+			ValidateFunc: validateAuthorizedKey,
+		},
+		"options": {
+			Description: "Options of the key e.g. from=\"10.0.0.0/8\" or no-pty",
+			Type:        schema.TypeList,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"comment": {
+			Description: "The comment of the key, defaults to the comment in 'key'",
+			Type:        schema.TypeString,
+			Optional:    true,
+			Computed:    true,
+		},
+		"target_user": {
+			Description:  "The user whose authorized_keys is managed, defaults to the SSH user",
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringMatch(usernameRegexp, "invalid username"),
+		},
+		"path": {
+			Description: "The path of the authorized_keys file, defaults to ~/.ssh/authorized_keys of the target user",
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"fingerprint_sha256": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+	})
+	for _, k := range []string{"host", "port", "user"} {
+		s[k].ForceNew = true
+	}
+
+	return &schema.Resource{
+		CreateContext: resourceAuthorizedKeyCreate,
+		ReadContext:   resourceAuthorizedKeyRead,
+		UpdateContext: resourceAuthorizedKeyUpdate,
+		DeleteContext: resourceAuthorizedKeyDelete,
+		Schema:        s,
+	}
+}
+
+func validateAuthorizedKey(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %q to be string", k)}
+	}
+	if _, _, _, _, err := gossh.ParseAuthorizedKey([]byte(v)); err != nil {
+		return nil, []error{fmt.Errorf("%q is not a valid public key: %w", k, err)}
+	}
+	return nil, nil
+}
+
+// authorizedKeyBlob returns the key type and base64 encoded key, which identify the entry
+func authorizedKeyBlob(key gossh.PublicKey) string {
+	return key.Type() + " " + base64.StdEncoding.EncodeToString(key.Marshal())
+}
+
+// authorizedKeyLine formats an authorized_keys line
+func authorizedKeyLine(key gossh.PublicKey, options []string, comment string) string {
+	line := authorizedKeyBlob(key)
+	if len(options) > 0 {
+		line = strings.Join(options, ",") + " " + line
+	}
+	if comment != "" {
+		line += " " + comment
+	}
+	return line
+}
+
+// authorizedKeysPathAssignment returns a shell assignment of the authorized_keys path to f
+func authorizedKeysPathAssignment(d *schema.ResourceData) string {
+	if path := d.Get("path").(string); path != "" {
+		return "f=" + shellQuote(path)
+	}
+	// Tilde expansion requires the username to be unquoted, it is validated
+	return fmt.Sprintf("f=~%s/.ssh/authorized_keys", d.Get("target_user").(string))
+}
+
+// editAuthorizedKeysCommand returns a command which removes all entries of blob from the
+// authorized_keys file and appends line when not empty. Concurrent edits are serialized
+// using a lock directory, the file is rewritten in place to keep its ownership and mode.
+// It is left as is when it can not be read. The directory of the file is owned by targetUser when
+// it is created, or when ownDir is set as it is the ~/.ssh directory of targetUser
+func editAuthorizedKeysCommand(pathAssignment, targetUser string, ownDir bool, blob, line string) string {
+	script := []string{
+		pathAssignment,
+		`d=$(dirname "$f")`,
+		fmt.Sprintf(`own_dir=%t`, ownDir),
+		`if [ ! -d "$d" ]; then mkdir -p "$d" && chmod 0700 "$d" || exit 1; own_dir=true; fi`,
+		`lock="$f.lock"`,
+		fmt.Sprintf(`i=0; until mkdir "$lock" 2>/dev/null; do i=$((i+1)); [ $i -ge 30 ] && exit %d; sleep 1; done`, lockTimeoutExitCode),
+		`trap 'rmdir "$lock"' EXIT`,
+		`if [ ! -f "$f" ]; then touch "$f" && chmod 0600 "$f" || exit 1; fi`,
+		`tmp=$(mktemp "$f.XXXXXX") || exit 1`,
+		// grep exits with 1 when no lines remain, above that the file could not be read
+		fmt.Sprintf(`grep -v -F %s "$f" > "$tmp"; [ $? -le 1 ] || { rm -f "$tmp"; exit 1; }`, shellQuote(blob)),
+	}
+	if line != "" {
+		script = append(script, fmt.Sprintf(`printf '%%s\n' %s >> "$tmp"`, shellQuote(line)))
+	}
+	script = append(script, `cat "$tmp" > "$f"; rc=$?; rm -f "$tmp"`)
+	if targetUser != "" {
+		script = append(script,
+			fmt.Sprintf(`chown %s "$f" || exit 1`, targetUser),
+			fmt.Sprintf(`if [ "$own_dir" = true ]; then chown %s "$d" || exit 1; fi`, targetUser))
+	}
+	script = append(script, `exit $rc`)
+	return "sh -c " + shellQuote(strings.Join(script, "\n"))
+}
+
+func runAuthorizedKeysEdit(d *schema.ResourceData, m interface{}, line string) diag.Diagnostics {
+	config := m.(*Config)
+
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(d.Get("key").(string)))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	sshRetryConfig, err := newSSHRetryConfig(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ssh := newSSHConfig(d)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sshRetryConfig.timeout)
+	defer cancel()
+
+	ownDir := d.Get("path").(string) == ""
+	command := editAuthorizedKeysCommand(authorizedKeysPathAssignment(d), d.Get("target_user").(string), ownDir, authorizedKeyBlob(key), line)
+	result, err := runCommand(ctx, command, ssh, sshRetryConfig, config)
+	if err != nil {
+		return diagFromCommandError("edit authorized_keys", result, err)
+	}
+	switch result.ExitCode {
+	case 0:
+		return nil
+	case lockTimeoutExitCode:
+		return diag.FromErr(fmt.Errorf("timeout acquiring lock on authorized_keys, remove a stale .lock directory next to it if no other edit is running"))
+	default:
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("editing authorized_keys failed with exit code %d", result.ExitCode),
+			Detail:   result.Stderr,
+		}}
+	}
+}
+
+func resourceAuthorizedKeyWrite(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	key, comment, _, _, err := gossh.ParseAuthorizedKey([]byte(d.Get("key").(string)))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if v, ok := d.GetOk("comment"); ok {
+		comment = v.(string)
+	}
+	var options []string
+	for _, o := range d.Get("options").([]interface{}) {
+		options = append(options, o.(string))
+	}
+	if diags := runAuthorizedKeysEdit(d, m, authorizedKeyLine(key, options, comment)); hasErrors(diags) {
+		return diags
+	}
+	d.SetId(fmt.Sprintf("%s:%d:%s:%s", d.Get("host").(string), d.Get("port").(int), d.Get("target_user").(string), gossh.FingerprintSHA256(key)))
+	return resourceAuthorizedKeyRead(ctx, d, m)
+}
+
+func resourceAuthorizedKeyCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	return resourceAuthorizedKeyWrite(ctx, d, m)
+}
+
+func resourceAuthorizedKeyUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	return resourceAuthorizedKeyWrite(ctx, d, m)
+}
+
+func resourceAuthorizedKeyRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	config := m.(*Config)
+
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(d.Get("key").(string)))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	sshRetryConfig, err := newSSHRetryConfig(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ssh := newSSHConfig(d)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sshRetryConfig.timeout)
+	defer cancel()
+
+	blob := authorizedKeyBlob(key)
+	command := "sh -c " + shellQuote(fmt.Sprintf("%s\n[ -f \"$f\" ] || exit 0\ngrep -F %s \"$f\" || true", authorizedKeysPathAssignment(d), shellQuote(blob)))
+	result, err := runCommand(ctx, command, ssh, sshRetryConfig, config)
+	if err != nil {
+		return diagFromCommandError("read authorized_keys", result, err)
+	}
+	if result.ExitCode != 0 {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("reading authorized_keys failed with exit code %d", result.ExitCode),
+			Detail:   result.Stderr,
+		}}
+	}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		found, comment, options, _, err := gossh.ParseAuthorizedKey([]byte(line))
+		if err != nil || authorizedKeyBlob(found) != blob {
+			continue
+		}
+		_ = d.Set("comment", comment)
+		_ = d.Set("options", options)
+		_ = d.Set("fingerprint_sha256", gossh.FingerprintSHA256(found))
+		return diags
+	}
+	// Removed outside of Terraform
+	d.SetId("")
+	return diags
+}
+
+func resourceAuthorizedKeyDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := runAuthorizedKeysEdit(d, m, ""); hasErrors(diags) {
+		return diags
+	}
+	d.SetId("")
+	return nil
+}
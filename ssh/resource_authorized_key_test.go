@@ -0,0 +1,156 @@
+package ssh
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const testAuthorizedKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHbW8Q1rDAn8lEoFZnM9ZTb0s40Z3xkrMtZh6PnOHgQn alice@laptop"
+
+func TestEditAuthorizedKeysCommand(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh available")
+	}
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(testAuthorizedKey))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), ".ssh", "authorized_keys")
+	run := func(line string) {
+		t.Helper()
+		command := editAuthorizedKeysCommand("f="+shellQuote(path), "", false, authorizedKeyBlob(key), line)
+		if output, err := exec.Command(sh, "-c", command).CombinedOutput(); err != nil {
+			t.Fatalf("err: %v: %s", err, output)
+		}
+	}
+	read := func() string {
+		t.Helper()
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return string(content)
+	}
+
+	run(authorizedKeyLine(key, nil, "alice@laptop"))
+	if content := read(); content != authorizedKeyBlob(key)+" alice@laptop\n" {
+		t.Errorf("unexpected content after add: %q", content)
+	}
+
+	if err := os.WriteFile(path, []byte("ssh-rsa AAAA other\n"+read()), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	run(authorizedKeyLine(key, []string{`from="10.0.0.0/8"`, "no-pty"}, "alice"))
+	expected := "ssh-rsa AAAA other\n" + `from="10.0.0.0/8",no-pty ` + authorizedKeyBlob(key) + " alice\n"
+	if content := read(); content != expected {
+		t.Errorf("unexpected content after update: %q", content)
+	}
+
+	run("")
+	if content := read(); content != "ssh-rsa AAAA other\n" {
+		t.Errorf("unexpected content after remove: %q", content)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("expected lock to be released")
+	}
+}
+
+func TestEditAuthorizedKeysCommand_unreadable(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh available")
+	}
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(testAuthorizedKey))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "authorized_keys")
+	content := "ssh-rsa AAAA other\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	cmd := exec.Command(sh, "-c", editAuthorizedKeysCommand("f="+shellQuote(path), "", false, authorizedKeyBlob(key), ""))
+	if os.Geteuid() == 0 {
+		// root reads files regardless of their mode, fail grep as a read error would
+		bin := filepath.Join(dir, "bin")
+		if err := os.Mkdir(bin, 0700); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(bin, "grep"), []byte("#!/bin/sh\nexit 2\n"), 0700); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	} else {
+		if err := os.Chmod(path, 0200); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	if output, err := cmd.CombinedOutput(); err == nil {
+		t.Fatalf("expected the edit to fail: %s", output)
+	}
+	_ = os.Chmod(path, 0600)
+	if got, err := os.ReadFile(path); err != nil || string(got) != content {
+		t.Errorf("expected authorized_keys to be kept, got %q %v", got, err)
+	}
+}
+
+func TestEditAuthorizedKeysCommand_chown(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh available")
+	}
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(testAuthorizedKey))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// chown records its arguments instead of changing ownership
+	bin := t.TempDir()
+	log := filepath.Join(bin, "chown.log")
+	if err := os.WriteFile(filepath.Join(bin, "chown"), []byte("#!/bin/sh\necho \"$@\" >> "+shellQuote(log)+"\n"), 0700); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := map[string]struct {
+		existingDir bool
+		ownDir      bool
+		want        string
+	}{
+		"existing directory":        {existingDir: true, want: "alice FILE\n"},
+		"created directory":         {want: "alice FILE\nalice DIR\n"},
+		"existing ~/.ssh directory": {existingDir: true, ownDir: true, want: "alice FILE\nalice DIR\n"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_ = os.Remove(log)
+			dir := filepath.Join(t.TempDir(), "keys")
+			if c.existingDir {
+				if err := os.Mkdir(dir, 0755); err != nil {
+					t.Fatalf("err: %v", err)
+				}
+			}
+			path := filepath.Join(dir, "authorized_keys")
+			command := editAuthorizedKeysCommand("f="+shellQuote(path), "alice", c.ownDir, authorizedKeyBlob(key), authorizedKeyLine(key, nil, ""))
+			cmd := exec.Command(sh, "-c", command)
+			cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("err: %v: %s", err, output)
+			}
+			got, err := os.ReadFile(log)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			want := strings.NewReplacer("FILE", path, "DIR", dir).Replace(c.want)
+			if string(got) != want {
+				t.Errorf("expected chown %q, got %q", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceFile() *schema.Resource {
+	s := mergeSchema(connectionSchema(), fileSchema(false), map[string]*schema.Schema{
+		"transfer_protocol": transferProtocolSchema(),
+		"sha256": {
+			Description: "The hex encoded SHA256 checksum of the remote file content",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+	})
+	for _, k := range []string{"host", "port", "user", "destination"} {
+		s[k].ForceNew = true
+	}
+	s["permissions"].DiffSuppressFunc = suppressEquivalentFileMode
+
+	return &schema.Resource{
+		CreateContext: resourceFileCreate,
+		ReadContext:   resourceFileRead,
+		UpdateContext: resourceFileUpdate,
+		DeleteContext: resourceFileDelete,
+		CustomizeDiff: resourceFileCustomDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceFileImport,
+		},
+		Schema: s,
+	}
+}
+
+// suppressEquivalentFileMode suppresses differences between notations of the same octal mode e.g. 644 and 0644
+func suppressEquivalentFileMode(_, old, new string, _ *schema.ResourceData) bool {
+	oldMode, err := strconv.ParseUint(old, 8, 32)
+	if err != nil {
+		return false
+	}
+	newMode, err := strconv.ParseUint(new, 8, 32)
+	if err != nil {
+		return false
+	}
+	return oldMode == newMode
+}
+
+// fileID returns the ID of a file resource in the user@host:port:/path format
+func fileID(user, host string, port int, path string) string {
+	return fmt.Sprintf("%s@%s:%s", user, net.JoinHostPort(host, strconv.Itoa(port)), path)
+}
+
+// parseFileID parses an ID in the user@host:port:/path format. IPv6 hosts are enclosed in brackets
+func parseFileID(id string) (user, host string, port int, path string, err error) {
+	user, rest, found := strings.Cut(id, "@")
+	if !found || user == "" {
+		return "", "", 0, "", fmt.Errorf("invalid ID %q, expected user@host:port:/path", id)
+	}
+	i := strings.Index(rest, ":/")
+	if i < 0 {
+		return "", "", 0, "", fmt.Errorf("invalid ID %q, expected user@host:port:/path", id)
+	}
+	host, portString, err := net.SplitHostPort(rest[:i])
+	if err != nil {
+		return "", "", 0, "", fmt.Errorf("invalid ID %q: %w", id, err)
+	}
+	if port, err = strconv.Atoi(portString); err != nil || port < 1 || port > 65535 {
+		return "", "", 0, "", fmt.Errorf("invalid ID %q, port must be between 1 and 65535, got: %s", id, portString)
+	}
+	return user, host, port, rest[i+1:], nil
+}
+
+// provisionFileFromResource returns the file described by the top level arguments of d
+func provisionFileFromResource(d interface{ Get(string) interface{} }) (provisionFile, diag.Diagnostics) {
+	mVi := make(map[string]interface{})
+	for k := range fileSchema(false) {
+		mVi[k] = d.Get(k)
+	}
+	return newProvisionFile(mVi)
+}
+
+// sha256 returns the hex encoded SHA256 checksum of the file content
+func (f provisionFile) sha256() (string, error) {
+	content := []byte(f.Content)
+	if f.Source != "" {
+		var err error
+		if content, err = os.ReadFile(f.Source); err != nil {
+			return "", err
+		}
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func resourceFileCustomDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	for _, k := range []string{"source", "content", "content_base64", "vars"} {
+		if !d.NewValueKnown(k) {
+			return d.SetNewComputed("sha256")
+		}
+	}
+	file, diags := provisionFileFromResource(d)
+	if len(diags) > 0 {
+		return fmt.Errorf("%s: %s", diags[0].Summary, diags[0].Detail)
+	}
+	sum, err := file.sha256()
+	if err != nil {
+		return err
+	}
+	if d.Get("sha256").(string) != sum {
+		return d.SetNew("sha256", sum)
+	}
+	return nil
+}
+
+func resourceFileImport(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	user, host, port, path, err := parseFileID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+	// Imported state has no defaults yet, Read needs them to connect
+	for k, v := range resourceFile().Schema {
+		if v.Default != nil {
+			_ = d.Set(k, v.Default)
+		}
+	}
+	_ = d.Set("user", user)
+	_ = d.Set("host", host)
+	_ = d.Set("port", port)
+	_ = d.Set("destination", path)
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourceFileWrite(d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	file, diags := provisionFileFromResource(d)
+	if len(diags) > 0 {
+		return diags
+	}
+	sshRetryConfig, err := newSSHRetryConfig(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ssh := newSSHConfig(d)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sshRetryConfig.timeout)
+	defer cancel()
+
+	if err := copyFiles(ctx, sshRetryConfig.retryDelay, ssh, d.Get("transfer_protocol").(string), config, []provisionFile{file}); err != nil {
+		return diag.FromErr(fmt.Errorf("copying file to remote: %w", err))
+	}
+	return diags
+}
+
+func resourceFileCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := resourceFileWrite(d, m); hasErrors(diags) {
+		return diags
+	}
+	d.SetId(fileID(d.Get("user").(string), d.Get("host").(string), d.Get("port").(int), d.Get("destination").(string)))
+	return resourceFileRead(ctx, d, m)
+}
+
+func resourceFileUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := resourceFileWrite(d, m); hasErrors(diags) {
+		return diags
+	}
+	return resourceFileRead(ctx, d, m)
+}
+
+func resourceFileRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	config := m.(*Config)
+
+	sshRetryConfig, err := newSSHRetryConfig(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ssh := newSSHConfig(d)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sshRetryConfig.timeout)
+	defer cancel()
+
+	file, diags := readRemoteFile(ctx, d.Get("destination").(string), ssh, sshRetryConfig, config)
+	if hasErrors(diags) {
+		return diags
+	}
+	if file == nil {
+		// Removed outside of Terraform
+		d.SetId("")
+		return diags
+	}
+	sum := sha256.Sum256(file.Content)
+	_ = d.Set("sha256", hex.EncodeToString(sum[:]))
+
+	// Only track metadata which is managed, so unmanaged values don't show as drift
+	if d.Get("permissions").(string) != "" {
+		_ = d.Set("permissions", file.Mode)
+	}
+	if d.Get("owner").(string) != "" {
+		_ = d.Set("owner", file.Owner)
+	}
+	if d.Get("group").(string) != "" {
+		_ = d.Set("group", file.Group)
+	}
+	return diags
+}
+
+func resourceFileDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	sshRetryConfig, err := newSSHRetryConfig(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ssh := newSSHConfig(d)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sshRetryConfig.timeout)
+	defer cancel()
+
+	command := "rm -f " + shellQuote(d.Get("destination").(string))
+	result, err := runCommand(ctx, command, ssh, sshRetryConfig, config)
+	if err != nil {
+		return diagFromCommandError(command, result, err)
+	}
+	if result.ExitCode != 0 {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("removing remote file %s failed with exit code %d", d.Get("destination").(string), result.ExitCode),
+			Detail:   result.Stderr,
+		}}
+	}
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,211 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceFileBlock() *schema.Resource {
+	s := mergeSchema(connectionSchema(), map[string]*schema.Schema{
+		"path": {
+			Description: "The path of the remote file",
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+		},
+		"block": {
+			Description: "The content of the managed block",
+			Type:        schema.TypeString,
+			Required:    true,
+		},
+		"marker": {
+			Description: "The marker line template, {mark} is replaced by 'marker_begin' and 'marker_end'",
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     "# {mark} TERRAFORM MANAGED BLOCK",
+		},
+		"marker_begin": {
+			Type:     schema.TypeString,
+			Optional: true,
+			ForceNew: true,
+			Default:  "BEGIN",
+		},
+		"marker_end": {
+			Type:     schema.TypeString,
+			Optional: true,
+			ForceNew: true,
+			Default:  "END",
+		},
+		"create": {
+			Description: "Create the file when it does not exist",
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+		},
+		"transfer_protocol": transferProtocolSchema(),
+	})
+	for _, k := range []string{"host", "port", "user"} {
+		s[k].ForceNew = true
+	}
+
+	return &schema.Resource{
+		CreateContext: resourceFileBlockCreate,
+		ReadContext:   resourceFileBlockRead,
+		UpdateContext: resourceFileBlockUpdate,
+		DeleteContext: resourceFileBlockDelete,
+		CustomizeDiff: resourceFileBlockCustomDiff,
+		Schema:        s,
+	}
+}
+
+// blockEdit holds the desired state of a marker delimited block
+type blockEdit struct {
+	Begin string
+	End   string
+	Block string
+}
+
+func newBlockEdit(d interface{ Get(string) interface{} }) *blockEdit {
+	marker := d.Get("marker").(string)
+	return &blockEdit{
+		Begin: strings.ReplaceAll(marker, "{mark}", d.Get("marker_begin").(string)),
+		End:   strings.ReplaceAll(marker, "{mark}", d.Get("marker_end").(string)),
+		Block: d.Get("block").(string),
+	}
+}
+
+// find returns the lines of content and the indexes of the begin and end markers in them, which are -1 when
+// content has no block. A begin marker without an end marker is an error, as the end of the block is not known
+func (e *blockEdit) find(content string) ([]string, int, int, error) {
+	lines := splitLines(content)
+	for i, l := range lines {
+		if l != e.Begin {
+			continue
+		}
+		for j := i + 1; j < len(lines); j++ {
+			if lines[j] == e.End {
+				return lines, i, j, nil
+			}
+		}
+		return nil, -1, -1, fmt.Errorf("found the begin marker %q without the end marker %q", e.Begin, e.End)
+	}
+	return lines, -1, -1, nil
+}
+
+// current returns the lines between the markers in content, found is false when content has no block
+func (e *blockEdit) current(content string) (block string, found bool, err error) {
+	lines, begin, end, err := e.find(content)
+	if err != nil || begin < 0 {
+		return "", false, err
+	}
+	return joinLines(lines[begin+1 : end]), true, nil
+}
+
+// replace returns content with the lines between the markers replaced by block.
+// A block without markers in content is appended. An empty block removes the markers as well
+func (e *blockEdit) replace(content string, block string) (string, error) {
+	lines, begin, end, err := e.find(content)
+	if err != nil {
+		return "", err
+	}
+	var managed []string
+	if block != "" {
+		managed = append([]string{e.Begin}, splitLines(block)...)
+		managed = append(managed, e.End)
+	}
+	var result []string
+	if begin >= 0 {
+		result = append(result, lines[:begin]...)
+		result = append(result, managed...)
+		result = append(result, lines[end+1:]...)
+	} else {
+		result = append(lines, managed...)
+	}
+	newContent := joinLines(result)
+	if newContent == joinLines(lines) {
+		return content, nil
+	}
+	return newContent, nil
+}
+
+func (e *blockEdit) apply(content string) (string, error) {
+	return e.replace(content, e.Block)
+}
+
+// remove returns content without the managed block, used on destroy
+func (e *blockEdit) remove(content string) (string, error) {
+	return e.replace(content, "")
+}
+
+func resourceFileBlockCustomDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	marker := d.Get("marker").(string)
+	if d.NewValueKnown("marker") && !strings.Contains(marker, "{mark}") {
+		return fmt.Errorf("'marker' must contain {mark} to distinguish the begin and end markers")
+	}
+	return nil
+}
+
+func resourceFileBlockApply(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	editor, err := newRemoteEditor(d, m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	edit := newBlockEdit(d)
+	if diags := editor.apply(d.Get("create").(bool), edit.apply); hasErrors(diags) {
+		return diags
+	}
+	d.SetId(fmt.Sprintf("%s#%s", fileID(d.Get("user").(string), d.Get("host").(string), d.Get("port").(int), editor.path), edit.Begin))
+	return resourceFileBlockRead(ctx, d, m)
+}
+
+func resourceFileBlockCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	return resourceFileBlockApply(ctx, d, m)
+}
+
+func resourceFileBlockUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	return resourceFileBlockApply(ctx, d, m)
+}
+
+func resourceFileBlockRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	editor, err := newRemoteEditor(d, m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	content, diags := editor.read()
+	if hasErrors(diags) {
+		return diags
+	}
+	edit := newBlockEdit(d)
+	block, found, err := edit.current(content)
+	if err != nil {
+		return append(diags, diag.FromErr(fmt.Errorf("reading %s: %w", editor.path, err))...)
+	}
+	if !found {
+		// Removed outside of Terraform, the block is added again
+		d.SetId("")
+		return diags
+	}
+	if !slices.Equal(splitLines(block), splitLines(edit.Block)) {
+		// Changed outside of Terraform, the plan shows the difference
+		_ = d.Set("block", block)
+	}
+	return diags
+}
+
+func resourceFileBlockDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	editor, err := newRemoteEditor(d, m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if diags := editor.apply(false, newBlockEdit(d).remove); hasErrors(diags) {
+		return diags
+	}
+	d.SetId("")
+	return nil
+}
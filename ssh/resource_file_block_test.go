@@ -0,0 +1,61 @@
+package ssh
+
+import (
+	"testing"
+)
+
+func TestBlockEditApply(t *testing.T) {
+	edit := &blockEdit{
+		Begin: "# BEGIN TERRAFORM MANAGED BLOCK",
+		End:   "# END TERRAFORM MANAGED BLOCK",
+		Block: "Match User sftp\n  ForceCommand internal-sftp\n",
+	}
+	config := "Port 22\n"
+	managed := "Port 22\n# BEGIN TERRAFORM MANAGED BLOCK\nMatch User sftp\n  ForceCommand internal-sftp\n# END TERRAFORM MANAGED BLOCK\n"
+
+	if result, _ := edit.apply(config); result != managed {
+		t.Errorf("unexpected content after insert: %q", result)
+	}
+	if result, _ := edit.apply(managed); result != managed {
+		t.Errorf("expected apply to be idempotent, got %q", result)
+	}
+
+	updated := &blockEdit{Begin: edit.Begin, End: edit.End, Block: "Match User backup\n"}
+	expected := "Port 22\n# BEGIN TERRAFORM MANAGED BLOCK\nMatch User backup\n# END TERRAFORM MANAGED BLOCK\n"
+	if result, _ := updated.apply(managed + "UsePAM yes\n"); result != expected+"UsePAM yes\n" {
+		t.Errorf("unexpected content after update: %q", result)
+	}
+
+	if result, _ := edit.remove(managed); result != config {
+		t.Errorf("unexpected content after remove: %q", result)
+	}
+
+	// The end of a block without an end marker is not known, so it is not replaced
+	unterminated := "Port 22\n# BEGIN TERRAFORM MANAGED BLOCK\nMatch User sftp\n"
+	if result, err := edit.apply(unterminated); err == nil {
+		t.Errorf("expected error for a begin marker without an end marker, got %q", result)
+	}
+	if _, err := edit.remove(unterminated); err == nil {
+		t.Errorf("expected error removing a block without an end marker")
+	}
+}
+
+func TestBlockEditCurrent(t *testing.T) {
+	edit := &blockEdit{
+		Begin: "# BEGIN TERRAFORM MANAGED BLOCK",
+		End:   "# END TERRAFORM MANAGED BLOCK",
+	}
+	block, found, err := edit.current("Port 22\n# BEGIN TERRAFORM MANAGED BLOCK\nMatch User backup\n# END TERRAFORM MANAGED BLOCK\nUsePAM yes\n")
+	if err != nil || !found {
+		t.Fatalf("expected block, got found %t, err: %v", found, err)
+	}
+	if block != "Match User backup\n" {
+		t.Errorf("unexpected block %q", block)
+	}
+	if _, found, err := edit.current("Port 22\n"); found || err != nil {
+		t.Errorf("expected no block, got found %t, err: %v", found, err)
+	}
+	if _, _, err := edit.current("# BEGIN TERRAFORM MANAGED BLOCK\nMatch User backup\n"); err == nil {
+		t.Errorf("expected error for a begin marker without an end marker")
+	}
+}
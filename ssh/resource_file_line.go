@@ -0,0 +1,241 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+const (
+	LineStatePresent  = "present"
+	LineStateAbsent   = "absent"
+	LineStateReplaced = "replaced"
+)
+
+func resourceFileLine() *schema.Resource {
+	s := mergeSchema(connectionSchema(), map[string]*schema.Schema{
+		"path": {
+			Description: "The path of the remote file",
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+		},
+		"line": {
+			Description: "The line to ensure in the file",
+			Type:        schema.TypeString,
+			Optional:    true,
+		},
+		"regexp": {
+			Description:  "Regular expression matching the lines to replace or remove",
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringIsValidRegExp,
+		},
+		"state": {
+			Description:  "Options are 'present', 'absent' or 'replaced'",
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      LineStatePresent,
+			ValidateFunc: validation.StringInSlice([]string{LineStatePresent, LineStateAbsent, LineStateReplaced}, false),
+		},
+		"create": {
+			Description: "Create the file when it does not exist",
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+		},
+		"transfer_protocol": transferProtocolSchema(),
+	})
+	for _, k := range []string{"host", "port", "user"} {
+		s[k].ForceNew = true
+	}
+
+	return &schema.Resource{
+		CreateContext: resourceFileLineCreate,
+		ReadContext:   resourceFileLineRead,
+		UpdateContext: resourceFileLineUpdate,
+		DeleteContext: resourceFileLineDelete,
+		CustomizeDiff: resourceFileLineCustomDiff,
+		Schema:        s,
+	}
+}
+
+// lineEdit holds the desired state of a line
+type lineEdit struct {
+	Line   string
+	Regexp *regexp.Regexp
+	State  string
+}
+
+func newLineEdit(d interface{ Get(string) interface{} }) (*lineEdit, error) {
+	edit := &lineEdit{
+		Line:  d.Get("line").(string),
+		State: d.Get("state").(string),
+	}
+	if expr := d.Get("regexp").(string); expr != "" {
+		var err error
+		if edit.Regexp, err = regexp.Compile(expr); err != nil {
+			return nil, err
+		}
+	}
+	return edit, nil
+}
+
+func (e *lineEdit) matches(line string) bool {
+	if e.Regexp != nil {
+		return e.Regexp.MatchString(line)
+	}
+	return line == e.Line
+}
+
+// apply returns content with the line edit applied. When the line is present, the last
+// line matching regexp is replaced and other matches are left alone. When no line
+// matches it is appended, unless state is 'replaced'. When absent, all matches are removed
+func (e *lineEdit) apply(content string) string {
+	lines := splitLines(content)
+	switch e.State {
+	case LineStateAbsent:
+		kept := make([]string, 0, len(lines))
+		for _, l := range lines {
+			if !e.matches(l) {
+				kept = append(kept, l)
+			}
+		}
+		lines = kept
+	default:
+		last := -1
+		for i, l := range lines {
+			if l == e.Line {
+				return content
+			}
+			if e.matches(l) {
+				last = i
+			}
+		}
+		if last >= 0 {
+			lines[last] = e.Line
+		} else if e.State == LineStatePresent {
+			lines = append(lines, e.Line)
+		}
+	}
+	newContent := joinLines(lines)
+	if newContent == joinLines(splitLines(content)) {
+		return content
+	}
+	return newContent
+}
+
+// replace returns a function applying e to content which held the line of prior. The line
+// of prior is removed when e did not replace it, so changing 'line' does not leave it behind
+func (e *lineEdit) replace(prior *lineEdit) func(string) string {
+	return func(content string) string {
+		content = e.apply(content)
+		if prior.Line == e.Line {
+			return content
+		}
+		return prior.remove(content)
+	}
+}
+
+// remove returns content without the managed line, used on destroy
+func (e *lineEdit) remove(content string) string {
+	if e.State == LineStateAbsent {
+		return content
+	}
+	removed := &lineEdit{Line: e.Line, State: LineStateAbsent}
+	return removed.apply(content)
+}
+
+func resourceFileLineCustomDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	state := d.Get("state").(string)
+	if state != LineStateAbsent && d.NewValueKnown("line") && d.Get("line").(string) == "" {
+		return fmt.Errorf("'line' must be set when state is '%s'", state)
+	}
+	if state == LineStateReplaced && d.NewValueKnown("regexp") && d.Get("regexp").(string) == "" {
+		return fmt.Errorf("'regexp' must be set when state is '%s'", state)
+	}
+	return nil
+}
+
+// priorValues gets the values of a resource before the planned changes
+type priorValues struct {
+	d *schema.ResourceData
+}
+
+func (p priorValues) Get(key string) interface{} {
+	value, _ := p.d.GetChange(key)
+	return value
+}
+
+func resourceFileLineApply(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	editor, err := newRemoteEditor(d, m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	edit, err := newLineEdit(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	apply := edit.apply
+	if !d.IsNewResource() {
+		prior, err := newLineEdit(priorValues{d})
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		apply = edit.replace(prior)
+	}
+	if diags := editor.apply(d.Get("create").(bool), infallible(apply)); hasErrors(diags) {
+		return diags
+	}
+	d.SetId(fmt.Sprintf("%s#%x", fileID(d.Get("user").(string), d.Get("host").(string), d.Get("port").(int), editor.path), sha256.Sum256([]byte(edit.Line+"\n"+d.Get("regexp").(string)))))
+	return resourceFileLineRead(ctx, d, m)
+}
+
+func resourceFileLineCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	return resourceFileLineApply(ctx, d, m)
+}
+
+func resourceFileLineUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	return resourceFileLineApply(ctx, d, m)
+}
+
+func resourceFileLineRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	editor, err := newRemoteEditor(d, m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	edit, err := newLineEdit(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	inSync, diags := editor.inSync(infallible(edit.apply))
+	if hasErrors(diags) {
+		return diags
+	}
+	if !inSync {
+		// Changed outside of Terraform
+		d.SetId("")
+	}
+	return diags
+}
+
+func resourceFileLineDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	editor, err := newRemoteEditor(d, m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	edit, err := newLineEdit(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if diags := editor.apply(false, infallible(edit.remove)); hasErrors(diags) {
+		return diags
+	}
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,52 @@
+package ssh
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestLineEditApply(t *testing.T) {
+	hosts := "127.0.0.1 localhost\n10.0.0.5 db\n"
+	cases := []struct {
+		name     string
+		edit     lineEdit
+		content  string
+		expected string
+	}{
+		{"append", lineEdit{Line: "10.0.0.6 cache", State: LineStatePresent}, hosts, hosts + "10.0.0.6 cache\n"},
+		{"append to file without newline", lineEdit{Line: "b", State: LineStatePresent}, "a", "a\nb\n"},
+		{"already present", lineEdit{Line: "10.0.0.5 db", State: LineStatePresent}, hosts, hosts},
+		{"replace", lineEdit{Line: "10.0.0.7 db", Regexp: regexp.MustCompile(` db$`), State: LineStatePresent}, hosts, "127.0.0.1 localhost\n10.0.0.7 db\n"},
+		{"replaced without match", lineEdit{Line: "10.0.0.7 cache", Regexp: regexp.MustCompile(` cache$`), State: LineStateReplaced}, hosts, hosts},
+		{"absent", lineEdit{Regexp: regexp.MustCompile(`^10\.`), State: LineStateAbsent}, hosts, "127.0.0.1 localhost\n"},
+		{"absent without match", lineEdit{Line: "10.0.0.6 cache", State: LineStateAbsent}, hosts, hosts},
+		{"empty file", lineEdit{Line: "a", State: LineStatePresent}, "", "a\n"},
+	}
+	for _, c := range cases {
+		if result := c.edit.apply(c.content); result != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, result)
+		}
+	}
+
+	edit := lineEdit{Line: "10.0.0.7 db", Regexp: regexp.MustCompile(` db$`), State: LineStatePresent}
+	if result := edit.remove(edit.apply(hosts)); result != "127.0.0.1 localhost\n" {
+		t.Errorf("unexpected content after remove: %q", result)
+	}
+
+	replaceCases := []struct {
+		name     string
+		prior    lineEdit
+		edit     lineEdit
+		expected string
+	}{
+		{"line changed", lineEdit{Line: "10.0.0.5 db", State: LineStatePresent}, lineEdit{Line: "10.0.0.6 db", State: LineStatePresent}, "127.0.0.1 localhost\n10.0.0.6 db\n"},
+		{"line replaced in place", lineEdit{Line: "10.0.0.5 db", State: LineStatePresent}, lineEdit{Line: "10.0.0.6 db", Regexp: regexp.MustCompile(` db$`), State: LineStatePresent}, "127.0.0.1 localhost\n10.0.0.6 db\n"},
+		{"regexp changed", lineEdit{Line: "10.0.0.5 db", Regexp: regexp.MustCompile(` db$`), State: LineStatePresent}, lineEdit{Line: "10.0.0.5 db", State: LineStatePresent}, hosts},
+		{"prior absent", lineEdit{Line: "10.0.0.5 db", State: LineStateAbsent}, lineEdit{Line: "10.0.0.6 cache", State: LineStatePresent}, hosts + "10.0.0.6 cache\n"},
+	}
+	for _, c := range replaceCases {
+		if result := c.edit.replace(&c.prior)(hosts); result != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, result)
+		}
+	}
+}
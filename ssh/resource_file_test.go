@@ -0,0 +1,80 @@
+package ssh
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+)
+
+func TestParseFileID(t *testing.T) {
+	cases := []struct {
+		id   string
+		host string
+		port int
+		path string
+	}{
+		{"alpine@remote.host:22:/etc/app/app.yaml", "remote.host", 22, "/etc/app/app.yaml"},
+		{"alpine@[fd00::2]:2222:/etc/hosts", "fd00::2", 2222, "/etc/hosts"},
+	}
+	for _, c := range cases {
+		user, host, port, path, err := parseFileID(c.id)
+		if err != nil {
+			t.Fatalf("%s: %v", c.id, err)
+		}
+		if user != "alpine" || host != c.host || port != c.port || path != c.path {
+			t.Errorf("%s: unexpected %s %s %d %s", c.id, user, host, port, path)
+		}
+		if id := fileID(user, host, port, path); id != c.id {
+			t.Errorf("expected %s, got %s", c.id, id)
+		}
+	}
+
+	for _, id := range []string{"remote.host:22:/etc/hosts", "alpine@remote.host:/etc/hosts", "alpine@remote.host:22", "alpine@remote.host:0:/etc/hosts", "alpine@remote.host:65536:/etc/hosts", "alpine@remote.host:ssh:/etc/hosts"} {
+		if _, _, _, _, err := parseFileID(id); err == nil {
+			t.Errorf("%s: expected error", id)
+		}
+	}
+}
+
+func TestSuppressEquivalentFileMode(t *testing.T) {
+	if !suppressEquivalentFileMode("permissions", "0644", "644", nil) {
+		t.Errorf("expected 0644 and 644 to be equivalent")
+	}
+	if suppressEquivalentFileMode("permissions", "0644", "0600", nil) {
+		t.Errorf("expected 0644 and 0600 to differ")
+	}
+	if suppressEquivalentFileMode("permissions", "0644", "u+x", nil) {
+		t.Errorf("expected symbolic modes not to be suppressed")
+	}
+}
+
+func TestResourceFileDelete_quoting(t *testing.T) {
+	s := sshtest.NewServer(t)
+	destination := s.Path("my $HOME file")
+	if err := os.WriteFile(destination, []byte("content"), 0644); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	d := schema.TestResourceDataRaw(t, resourceFile().Schema, map[string]interface{}{
+		"host":        s.Host,
+		"port":        int(testPort(t, s)),
+		"user":        s.User,
+		"password":    s.Password,
+		"timeout":     testTimeout.String(),
+		"retry_delay": testRetryDelay.String(),
+		"destination": destination,
+	})
+
+	if diags := resourceFileDelete(context.Background(), d, newConfig(os.DevNull)); hasErrors(diags) {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	// In double quotes $HOME expands, and rm -f succeeds without removing the file
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", destination, err)
+	}
+	if got := s.Commands(); len(got) != 1 || got[0] != "rm -f '"+destination+"'" {
+		t.Errorf("expected the destination to be quoted, ran %v", got)
+	}
+}
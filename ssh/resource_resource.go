@@ -3,446 +3,1166 @@ package ssh
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"math/rand"
-	"net/http"
 	"os"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/resourcevalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	fwdiag "github.com/hashicorp/terraform-plugin-framework/diag"
+	fwpath "github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	fwschema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/loafoe/easyssh-proxy/v2"
 )
 
-func resourceResource() *schema.Resource {
-	return &schema.Resource{
-		SchemaVersion: 5,
-		CreateContext: resourceResourceCreate,
-		ReadContext:   resourceResourceRead,
-		UpdateContext: resourceResourceUpdate,
-		DeleteContext: resourceResourceDelete,
-		CustomizeDiff: customDiff,
-		Importer: &schema.ResourceImporter{
-			StateContext: schema.ImportStatePassthroughContext,
+var (
+	_ resource.ResourceWithConfigure        = &sshResource{}
+	_ resource.ResourceWithConfigValidators = &sshResource{}
+	_ resource.ResourceWithModifyPlan       = &sshResource{}
+	_ resource.ResourceWithImportState      = &sshResource{}
+	_ resource.ResourceWithUpgradeState     = &sshResource{}
+	_ resource.ResourceWithValidateConfig   = &sshResource{}
+)
+
+// sshResource implements ssh_resource and, when sensitive, ssh_sensitive_resource.
+// The state of both is compatible with their former SDK implementations
+type sshResource struct {
+	sensitive bool
+	config    *Config
+}
+
+func newResource() resource.Resource {
+	return &sshResource{}
+}
+
+type sshResourceModel struct {
+	ID                             types.String `tfsdk:"id"`
+	When                           types.String `tfsdk:"when"`
+	Triggers                       types.Map    `tfsdk:"triggers"`
+	Host                           types.String `tfsdk:"host"`
+	Hosts                          types.List   `tfsdk:"hosts"`
+	Strategy                       types.String `tfsdk:"strategy"`
+	BatchSize                      types.Int64  `tfsdk:"batch_size"`
+	MaxFailPercentage              types.Int64  `tfsdk:"max_fail_percentage"`
+	Port                           types.Int64  `tfsdk:"port"`
+	BastionHost                    types.String `tfsdk:"bastion_host"`
+	BastionPort                    types.Int64  `tfsdk:"bastion_port"`
+	User                           types.String `tfsdk:"user"`
+	HostUser                       types.String `tfsdk:"host_user"`
+	BastionUser                    types.String `tfsdk:"bastion_user"`
+	Password                       types.String `tfsdk:"password"`
+	BastionPassword                types.String `tfsdk:"bastion_password"`
+	PrivateKey                     types.String `tfsdk:"private_key"`
+	HostPrivateKey                 types.String `tfsdk:"host_private_key"`
+	BastionPrivateKey              types.String `tfsdk:"bastion_private_key"`
+	PasswordWO                     types.String `tfsdk:"password_wo"`
+	BastionPasswordWO              types.String `tfsdk:"bastion_password_wo"`
+	PrivateKeyWO                   types.String `tfsdk:"private_key_wo"`
+	BastionPrivateKeyWO            types.String `tfsdk:"bastion_private_key_wo"`
+	PrivateKeyPassphraseWO         types.String `tfsdk:"private_key_passphrase_wo"`
+	BastionPrivateKeyPassphraseWO  types.String `tfsdk:"bastion_private_key_passphrase_wo"`
+	Agent                          types.Bool   `tfsdk:"agent"`
+	PreCommands                    types.List   `tfsdk:"pre_commands"`
+	Commands                       types.List   `tfsdk:"commands"`
+	Command                        types.List   `tfsdk:"command"`
+	ExecutedCommands               types.List   `tfsdk:"executed_commands"`
+	CommandsAfterFileChanges       types.Bool   `tfsdk:"commands_after_file_changes"`
+	OnFailure                      types.String `tfsdk:"on_failure"`
+	RollbackCommands               types.List   `tfsdk:"rollback_commands"`
+	CompletedCommands              types.Int64  `tfsdk:"completed_commands"`
+	ForceRerunAll                  types.Bool   `tfsdk:"force_rerun_all"`
+	Timeout                        types.String `tfsdk:"timeout"`
+	IgnoreNoSupportedMethodsRemain types.Bool   `tfsdk:"ignore_no_supported_methods_remain"`
+	RetryDelay                     types.String `tfsdk:"retry_delay"`
+	TransferProtocol               types.String `tfsdk:"transfer_protocol"`
+	Result                         types.String `tfsdk:"result"`
+	Results                        types.Map    `tfsdk:"results"`
+	File                           types.Set    `tfsdk:"file"`
+
+	// checkpoints are kept in private state
+	checkpoints checkpoints
+}
+
+type fileModel struct {
+	Source           types.String `tfsdk:"source"`
+	Content          types.String `tfsdk:"content"`
+	ContentBase64    types.String `tfsdk:"content_base64"`
+	Template         types.Bool   `tfsdk:"template"`
+	Vars             types.Map    `tfsdk:"vars"`
+	Destination      types.String `tfsdk:"destination"`
+	Permissions      types.String `tfsdk:"permissions"`
+	Owner            types.String `tfsdk:"owner"`
+	Group            types.String `tfsdk:"group"`
+	CreateParentDirs types.Bool   `tfsdk:"create_parent_dirs"`
+	DirPermissions   types.String `tfsdk:"dir_permissions"`
+	DirOwner         types.String `tfsdk:"dir_owner"`
+	DirGroup         types.String `tfsdk:"dir_group"`
+}
+
+func (r *sshResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	if r.sensitive {
+		resp.TypeName = req.ProviderTypeName + "_sensitive_resource"
+		return
+	}
+	resp.TypeName = req.ProviderTypeName + "_resource"
+}
+
+func (r *sshResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = sshResourceSchema(r.sensitive)
+}
+
+func sshResourceSchema(sensitive bool) fwschema.Schema {
+	version := int64(6)
+	if sensitive {
+		version = 2
+	}
+	return fwschema.Schema{
+		Version: version,
+		Attributes: map[string]fwschema.Attribute{
+			"id": fwschema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"when": fwschema.StringAttribute{
+				Description: "Determines when the commands is to be executed. Options are 'create' or 'destroy'",
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("create"),
+				Validators: []validator.String{
+					stringvalidator.OneOf("create", "destroy"),
+				},
+			},
+			"triggers": fwschema.MapAttribute{
+				Description: "A map of arbitrary strings that, when changed, will force the 'hsdp_container_host_exec' resource to be replaced, re-running any associated commands.",
+				ElementType: types.StringType,
+				Optional:    true,
+				PlanModifiers: []planmodifier.Map{
+					mapRequiresReplaceUnlessImported(),
+				},
+			},
+			"host": fwschema.StringAttribute{
+				Description: "The host to connect to, conflicts with 'hosts'. IPv6 addresses may be enclosed in brackets and include a zone",
+				Optional:    true,
+				PlanModifiers: []planmodifier.String{
+					requiresReplaceUnlessImported(),
+				},
+				Validators: []validator.String{
+					hostValidator{},
+				},
+			},
+			"hosts": fwschema.ListAttribute{
+				Description: "The hosts to provision, conflicts with 'host'. Entries may include a port as host:port or [host]:port",
+				ElementType: types.StringType,
+				Optional:    true,
+				Validators: []validator.List{
+					listvalidator.SizeAtLeast(1),
+					listvalidator.UniqueValues(),
+					listvalidator.ValueStringsAre(hostValidator{port: true}),
+				},
+			},
+			"strategy": fwschema.StringAttribute{
+				Description: "How 'hosts' are provisioned. Options are 'parallel', 'serial' or 'rolling', defaults to 'parallel'",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(StrategyParallel, StrategySerial, StrategyRolling),
+					stringvalidator.AlsoRequires(fwpath.MatchRoot("hosts")),
+				},
+			},
+			"batch_size": fwschema.Int64Attribute{
+				Description: "The number of hosts provisioned at a time with the 'rolling' strategy, defaults to 1",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+					int64validator.AlsoRequires(fwpath.MatchRoot("hosts")),
+				},
+			},
+			"max_fail_percentage": fwschema.Int64Attribute{
+				Description: "The percentage of 'hosts' which may fail without failing the resource, defaults to 0. No further batches are started once it is exceeded",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.Between(0, 100),
+					int64validator.AlsoRequires(fwpath.MatchRoot("hosts")),
+				},
+			},
+			"port": fwschema.Int64Attribute{
+				Optional: true,
+				Computed: true,
+				Default:  int64default.StaticInt64(22),
+				Validators: []validator.Int64{
+					int64validator.Between(1, 65535),
+				},
+			},
+			"bastion_host": fwschema.StringAttribute{
+				Optional: true,
+				Validators: []validator.String{
+					hostValidator{},
+				},
+			},
+			"bastion_port": fwschema.Int64Attribute{
+				Optional: true,
+				Computed: true,
+				Default:  int64default.StaticInt64(22),
+				Validators: []validator.Int64{
+					int64validator.Between(1, 65535),
+				},
+			},
+			"user": fwschema.StringAttribute{
+				Optional: true,
+				PlanModifiers: []planmodifier.String{
+					requiresReplaceUnlessImported(),
+				},
+			},
+			"host_user": fwschema.StringAttribute{
+				Optional:           true,
+				DeprecationMessage: "Use 'user' and 'bastion_user'",
+				PlanModifiers: []planmodifier.String{
+					requiresReplaceUnlessImported(),
+				},
+			},
+			"bastion_user": fwschema.StringAttribute{
+				Optional: true,
+				PlanModifiers: []planmodifier.String{
+					requiresReplaceUnlessImported(),
+				},
+			},
+			"password": fwschema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+			},
+			"bastion_password": fwschema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+			},
+			"private_key": fwschema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+				Validators: []validator.String{
+					privateKeyValidator{},
+				},
+			},
+			"host_private_key": fwschema.StringAttribute{
+				Optional:           true,
+				Sensitive:          true,
+				DeprecationMessage: "Use 'private_key' and 'bastion_private_key'",
+				Validators: []validator.String{
+					privateKeyValidator{},
+				},
+			},
+			"bastion_private_key": fwschema.StringAttribute{
+				Optional:  true,
+				Sensitive: true,
+				Validators: []validator.String{
+					privateKeyValidator{},
+				},
+			},
+			"password_wo":                       writeOnlyAttribute("The SSH password to use for the host", "password"),
+			"bastion_password_wo":               writeOnlyAttribute("The SSH password to use for the bastion host", "bastion_password"),
+			"private_key_wo":                    privateKeyAttribute(writeOnlyAttribute("The SSH private key to use", "private_key", "host_private_key")),
+			"bastion_private_key_wo":            privateKeyAttribute(writeOnlyAttribute("The SSH private key to use for the bastion host", "bastion_private_key")),
+			"private_key_passphrase_wo":         writeOnlyAttribute("The passphrase of the SSH private key, overrides SSH_PRIVATE_KEY_PASSPHRASE"),
+			"bastion_private_key_passphrase_wo": writeOnlyAttribute("The passphrase of the bastion SSH private key, overrides SSH_BASTION_PRIVATE_KEY_PASSPHRASE"),
+			"agent": fwschema.BoolAttribute{
+				Optional: true,
+				Computed: true,
+				Default:  booldefault.StaticBool(false),
+			},
+			"pre_commands": fwschema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(100),
+				},
+			},
+			"commands": fwschema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(100),
+				},
+			},
+			"commands_after_file_changes": fwschema.BoolAttribute{
+				Optional: true,
+				Computed: true,
+				Default:  booldefault.StaticBool(true),
+			},
+			"on_failure": fwschema.StringAttribute{
+				Description: "What to do when one of 'commands' fails. Options are 'fail', 'continue' or 'rollback', defaults to 'fail'",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(OnFailureFail, OnFailureContinue, OnFailureRollback),
+				},
+			},
+			"rollback_commands": fwschema.ListAttribute{
+				Description: "The commands undoing each of 'commands', run in reverse order for the completed commands when 'on_failure' is 'rollback'",
+				ElementType: types.StringType,
+				Optional:    true,
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(100),
+				},
+			},
+			"force_rerun_all": fwschema.BoolAttribute{
+				Description: "Run all commands when retrying a failed apply, rather than resuming from the first command which did not complete",
+				Optional:    true,
+			},
+			"executed_commands": fwschema.ListAttribute{
+				Description: "The commands run by the last apply, the plan shows which commands will run",
+				ElementType: types.StringType,
+				Computed:    true,
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"completed_commands": fwschema.Int64Attribute{
+				Description: "The number of 'commands' which completed in the last apply, the next apply resumes from the first command which did not",
+				Computed:    true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"timeout": fwschema.StringAttribute{
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString("5m"),
+				Validators: []validator.String{
+					durationValidator{},
+				},
+			},
+			"ignore_no_supported_methods_remain": fwschema.BoolAttribute{
+				Optional: true,
+				Computed: true,
+				Default:  booldefault.StaticBool(false),
+			},
+			"retry_delay": fwschema.StringAttribute{
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString("10s"),
+				Validators: []validator.String{
+					durationValidator{},
+				},
+			},
+			"transfer_protocol": fwschema.StringAttribute{
+				Description: "The protocol used to transfer files. Options are 'scp', 'sftp' or 'auto'",
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(TransferProtocolSCP),
+				Validators: []validator.String{
+					stringvalidator.OneOf(TransferProtocolSCP, TransferProtocolSFTP, TransferProtocolAuto),
+				},
+			},
+			"result": fwschema.StringAttribute{
+				Computed:  true,
+				Sensitive: sensitive,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"results": fwschema.MapAttribute{
+				Description: "The outcome per entry of 'hosts' with its 'stdout', 'status', which is 'success', 'failed' or 'skipped', and 'completed_commands'",
+				ElementType: types.ObjectType{AttrTypes: hostResultAttrTypes},
+				Computed:    true,
+				Sensitive:   sensitive,
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.UseStateForUnknown(),
+				},
+			},
 		},
-		StateUpgraders: []schema.StateUpgrader{
-			{
-				Type:    resourceResourceV0().CoreConfigSchema().ImpliedType(),
-				Upgrade: patchResourceV0,
-				Version: 0,
-			},
-			{
-				Type:    resourceResourceV1().CoreConfigSchema().ImpliedType(),
-				Upgrade: patchResourceV1,
-				Version: 1,
-			},
-			{
-				Type:    resourceResourceV2().CoreConfigSchema().ImpliedType(),
-				Upgrade: patchResourceV2,
-				Version: 2,
-			},
-			{
-				Type:    resourceResourceV3().CoreConfigSchema().ImpliedType(),
-				Upgrade: patchResourceV3,
-				Version: 3,
-			},
-			{
-				Type:    resourceResourceV4().CoreConfigSchema().ImpliedType(),
-				Upgrade: patchResourceV4,
-				Version: 4,
+		Blocks: map[string]fwschema.Block{
+			"file": fwschema.SetNestedBlock{
+				NestedObject: fwschema.NestedBlockObject{
+					Attributes: fileAttributes(sensitive),
+				},
+			},
+			"command": fwschema.ListNestedBlock{
+				Description: "A command with the inputs which make it run again on update, conflicts with 'commands'",
+				NestedObject: fwschema.NestedBlockObject{
+					Attributes: map[string]fwschema.Attribute{
+						"run": fwschema.StringAttribute{
+							Description: "The command to run",
+							Required:    true,
+						},
+						"triggers": fwschema.MapAttribute{
+							Description: "Values which run the command again on update when they change",
+							ElementType: types.StringType,
+							Optional:    true,
+						},
+						"run_on": fwschema.ListAttribute{
+							Description: "Destinations of 'file' blocks which run the command again on update when they change",
+							ElementType: types.StringType,
+							Optional:    true,
+						},
+					},
+				},
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(100),
+					listvalidator.ConflictsWith(fwpath.MatchRoot("commands")),
+				},
 			},
 		},
-		Schema: sshResourceSchema(false),
 	}
 }
 
-func customDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
-	if d.HasChange("file") || d.HasChange("commands") {
-		_ = d.SetNewComputed("result")
+// writeOnlyAttribute returns a sensitive attribute which is never stored in plan or state.
+// It conflicts with the attributes which are stored in state
+func writeOnlyAttribute(description string, conflictsWith ...string) fwschema.StringAttribute {
+	attribute := fwschema.StringAttribute{
+		Description: description + ". The value is not stored in state",
+		Optional:    true,
+		Sensitive:   true,
+		WriteOnly:   true,
 	}
-	return nil
+	for _, k := range conflictsWith {
+		attribute.Validators = append(attribute.Validators, stringvalidator.ConflictsWith(fwpath.MatchRoot(k)))
+	}
+	return attribute
 }
 
-func sshResourceSchema(sensitive bool) map[string]*schema.Schema {
-	return map[string]*schema.Schema{
-		"when": {
-			Description:  "Determines when the commands is to be executed. Options are 'create' or 'destroy'",
-			Type:         schema.TypeString,
-			Optional:     true,
-			Default:      "create",
-			ValidateFunc: validation.StringInSlice([]string{"create", "destroy"}, false),
+// privateKeyAttribute adds the validation of a private key to attribute
+func privateKeyAttribute(attribute fwschema.StringAttribute) fwschema.StringAttribute {
+	attribute.Validators = append(attribute.Validators, privateKeyValidator{})
+	return attribute
+}
+
+// fileAttributes returns the attributes of a file block, matching fileSchema
+func fileAttributes(sensitive bool) map[string]fwschema.Attribute {
+	return map[string]fwschema.Attribute{
+		"source": fwschema.StringAttribute{
+			Optional: true,
 		},
-		"triggers": {
-			Description: "A map of arbitrary strings that, when changed, will force the 'hsdp_container_host_exec' resource to be replaced, re-running any associated commands.",
-			Type:        schema.TypeMap,
+		"content": fwschema.StringAttribute{
+			Optional:  true,
+			Sensitive: sensitive,
+		},
+		"content_base64": fwschema.StringAttribute{
+			Description: "Base64 encoded content of the file, use for binary content",
 			Optional:    true,
-			ForceNew:    true,
+			Sensitive:   sensitive,
+			Validators: []validator.String{
+				base64Validator{},
+			},
 		},
-		"host": {
-			Type:     schema.TypeString,
+		"template": fwschema.BoolAttribute{
+			Description: "Render 'source' as a Go text/template using 'vars'",
+			Optional:    true,
+			Computed:    true,
+			Default:     booldefault.StaticBool(false),
+		},
+		"vars": fwschema.MapAttribute{
+			ElementType: types.StringType,
+			Optional:    true,
+			Sensitive:   sensitive,
+		},
+		"destination": fwschema.StringAttribute{
 			Required: true,
-			ForceNew: true,
 		},
-		"port": {
-			Type:     schema.TypeString,
+		"permissions": fwschema.StringAttribute{
 			Optional: true,
-			Default:  "22",
+			Validators: []validator.String{
+				fileModeValidator{},
+			},
 		},
-		"bastion_host": {
-			Type:     schema.TypeString,
+		"owner": fwschema.StringAttribute{
 			Optional: true,
 		},
-		"bastion_port": {
-			Type:     schema.TypeString,
+		"group": fwschema.StringAttribute{
 			Optional: true,
-			Default:  "22",
 		},
-		"user": {
-			Type:     schema.TypeString,
+		"create_parent_dirs": fwschema.BoolAttribute{
+			Description: "Create missing parent directories of the destination",
+			Optional:    true,
+			Computed:    true,
+			Default:     booldefault.StaticBool(false),
+		},
+		"dir_permissions": fwschema.StringAttribute{
 			Optional: true,
-			ForceNew: true,
+			Validators: []validator.String{
+				fileModeValidator{},
+			},
 		},
-		"host_user": {
-			Type:       schema.TypeString,
-			Optional:   true,
-			ForceNew:   true,
-			Deprecated: "Use 'user' and 'bastion_user'",
+		"dir_owner": fwschema.StringAttribute{
+			Optional: true,
 		},
-		"bastion_user": {
-			Type:     schema.TypeString,
+		"dir_group": fwschema.StringAttribute{
 			Optional: true,
-			ForceNew: true,
 		},
-		"password": {
-			Type:      schema.TypeString,
-			Optional:  true,
-			Sensitive: true,
+	}
+}
+
+// base64Validator validates that a string is base64 encoded
+type base64Validator struct{}
+
+func (v base64Validator) Description(_ context.Context) string {
+	return "value must be base64 encoded"
+}
+
+func (v base64Validator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v base64Validator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := base64.StdEncoding.DecodeString(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid base64 content", err.Error())
+	}
+}
+
+func (r *sshResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	config, ok := req.ProviderData.(*Config)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected provider data", fmt.Sprintf("expected *Config, got %T", req.ProviderData))
+		return
+	}
+	r.config = config
+}
+
+func (r *sshResource) ConfigValidators(_ context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		resourcevalidator.ExactlyOneOf(fwpath.MatchRoot("host"), fwpath.MatchRoot("hosts")),
+	}
+}
+
+func (r *sshResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data sshResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	validateRollbackCommands(&data, resp)
+	resp.Diagnostics.Append(validateCommandBlocks(ctx, &data)...)
+	if data.When.ValueString() != "destroy" {
+		return
+	}
+	for k, v := range data.writeOnly() {
+		if !v.IsNull() {
+			resp.Diagnostics.AddAttributeError(fwpath.Root(k), "Write-only attribute not available on destroy",
+				"Write-only attributes are not stored in state, so they can not be used when 'when' is 'destroy'")
+		}
+	}
+}
+
+// validateRollbackCommands checks 'rollback_commands' is set together with 'on_failure' being 'rollback',
+// and that it has no more entries than 'commands'
+func validateRollbackCommands(data *sshResourceModel, resp *resource.ValidateConfigResponse) {
+	if data.OnFailure.IsUnknown() || data.RollbackCommands.IsUnknown() {
+		return
+	}
+	rollback := data.OnFailure.ValueString() == OnFailureRollback
+	switch {
+	case rollback && data.RollbackCommands.IsNull():
+		resp.Diagnostics.AddAttributeError(fwpath.Root("rollback_commands"), "Missing rollback commands",
+			"'rollback_commands' must be set when 'on_failure' is 'rollback'")
+	case !rollback && !data.RollbackCommands.IsNull():
+		resp.Diagnostics.AddAttributeError(fwpath.Root("rollback_commands"), "Rollback commands not used",
+			"'rollback_commands' are only run when 'on_failure' is 'rollback'")
+	case rollback && !data.Commands.IsUnknown() && !data.Command.IsUnknown() && len(data.RollbackCommands.Elements()) > data.commandsLen():
+		resp.Diagnostics.AddAttributeError(fwpath.Root("rollback_commands"), "Too many rollback commands",
+			fmt.Sprintf("'rollback_commands' has %d entries, one per command is expected but there are %d commands",
+				len(data.RollbackCommands.Elements()), data.commandsLen()))
+	}
+}
+
+func (r *sshResource) UpgradeState(_ context.Context) map[int64]resource.StateUpgrader {
+	if r.sensitive {
+		return map[int64]resource.StateUpgrader{
+			0: legacyStateUpgrader("ssh_sensitive_resource", 0),
+			1: stringPortsStateUpgrader(),
+		}
+	}
+	upgraders := make(map[int64]resource.StateUpgrader)
+	for version := int64(0); version < 5; version++ {
+		upgraders[version] = legacyStateUpgrader("ssh_resource", version)
+	}
+	upgraders[5] = stringPortsStateUpgrader()
+	return upgraders
+}
+
+// ModifyPlan validates templates and the arguments which depend on each other, so mistakes fail the plan rather than
+// the apply. It marks the result as unknown when files or commands change, as these are provisioned again on update,
+// or when the last apply did not complete all commands. Results per host are also unknown when 'hosts' change
+// or a host did not succeed, and null when it is not set. The first update after an import provisions nothing
+func (r *sshResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+	var plan sshResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(plan.readWriteOnly(ctx, req.Config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(validateResource(ctx, &plan)...)
+	// Compared before the plan is modified, the ID only changes along with other arguments
+	updating := !req.State.Raw.IsNull() && !req.Plan.Raw.Equal(req.State.Raw)
+	var state *sshResourceModel
+	if !req.State.Raw.IsNull() {
+		state = &sshResourceModel{}
+		resp.Diagnostics.Append(req.State.Get(ctx, state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	fileChanged := state == nil || !plan.File.Equal(state.File)
+	if fileChanged {
+		var files []fileModel
+		resp.Diagnostics.Append(plan.File.ElementsAs(ctx, &files, false)...)
+		if err := validateFileTemplates(files); err != nil {
+			resp.Diagnostics.AddAttributeError(fwpath.Root("file"), "Invalid template", err.Error())
+		}
+	}
+	imported, diags := readImported(ctx, req.Private)
+	resp.Diagnostics.Append(diags...)
+	if imported || updating {
+		// The ID is derived from the target and the commands, an imported resource adopts the configuration
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, fwpath.Root("id"), planResourceID(ctx, &plan))...)
+	}
+	changed := state != nil && !imported && (fileChanged || !plan.commandsEqual(state))
+	incomplete := state != nil && !imported && state.completedCommands() < state.commandsLen()
+	if changed || incomplete {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, fwpath.Root("result"), types.StringUnknown())...)
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, fwpath.Root("completed_commands"), types.Int64Unknown())...)
+	}
+	executed, diags := planExecutedCommands(ctx, &plan, state, changed, incomplete)
+	resp.Diagnostics.Append(diags...)
+	if executed == nil && imported {
+		none := stringList(nil)
+		executed = &none
+	}
+	if executed != nil {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, fwpath.Root("executed_commands"), *executed)...)
+	}
+	resultsType := types.ObjectType{AttrTypes: hostResultAttrTypes}
+	switch {
+	case plan.Hosts.IsNull():
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, fwpath.Root("results"), types.MapNull(resultsType))...)
+	case changed || (state != nil && !plan.Hosts.Equal(state.Hosts)):
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, fwpath.Root("results"), types.MapUnknown(resultsType))...)
+	case state != nil && !imported && state.hostsIncomplete():
+		// Hosts which failed or were skipped are provisioned again
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, fwpath.Root("result"), types.StringUnknown())...)
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, fwpath.Root("results"), types.MapUnknown(resultsType))...)
+	}
+}
+
+func (r *sshResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data sshResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(data.readWriteOnly(ctx, req.Config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var prior *sshResourceModel
+	if data.When.ValueString() == "create" {
+		// Replacing a resource whose create failed resumes its progress
+		prior = r.config.takeFailedCreate(&data)
+		resp.Diagnostics.Append(frameworkDiagnostics(provision(ctx, &data, prior, r.config))...)
+	} else {
+		resp.Diagnostics.Append(validateResource(ctx, &data)...)
+	}
+	// The progress of a failed apply is kept in the state of the tainted resource
+	if resp.Diagnostics.HasError() && !data.provisioned() {
+		return
+	}
+	resp.Diagnostics.Append(writeCheckpoints(ctx, resp.Private, data.checkpoints)...)
+
+	data.ID = types.StringValue(resourceID(&data))
+	data.resolveUnknown(prior)
+	data.clearWriteOnly()
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *sshResource) Read(_ context.Context, _ resource.ReadRequest, _ *resource.ReadResponse) {
+	// The outcome of commands can not be read back, state is kept as is
+}
+
+func (r *sshResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, prior sshResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &prior)...)
+	resp.Diagnostics.Append(data.readWriteOnly(ctx, req.Config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	imported, diags := readImported(ctx, req.Private)
+	resp.Diagnostics.Append(diags...)
+	data.ID = types.StringValue(resourceID(&data))
+	if imported {
+		// The host was provisioned before it was imported, its configuration is adopted
+		resp.Diagnostics.Append(resp.Private.SetKey(ctx, importedKey, nil)...)
+	} else if data.When.ValueString() == "create" {
+		// Set by provisioning once it started, so a failed apply records its progress
+		data.CompletedCommands = types.Int64Unknown()
+		data.Results = types.MapUnknown(types.ObjectType{AttrTypes: hostResultAttrTypes})
+		prior.checkpoints, diags = readCheckpoints(ctx, req.Private)
+		resp.Diagnostics.Append(diags...)
+		resp.Diagnostics.Append(frameworkDiagnostics(provision(ctx, &data, &prior, r.config))...)
+		if resp.Diagnostics.HasError() && !data.provisioned() {
+			return
+		}
+		resp.Diagnostics.Append(writeCheckpoints(ctx, resp.Private, data.checkpoints)...)
+	}
+	data.resolveUnknown(&prior)
+	data.clearWriteOnly()
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *sshResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data sshResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	switch data.When.ValueString() {
+	case "destroy":
+		resp.Diagnostics.Append(frameworkDiagnostics(provision(ctx, &data, nil, r.config))...)
+	case "create":
+		if data.completedCommands() < data.commandsLen() || data.hostsIncomplete() {
+			// A tainted resource is destroyed before the create which replaces it, which resumes its progress
+			cp, diags := readCheckpoints(ctx, req.Private)
+			resp.Diagnostics.Append(diags...)
+			if cp == nil {
+				cp = stateCheckpoints(ctx, &data)
+			}
+			data.checkpoints = cp
+			r.config.keepFailedCreate(&data)
+		}
+	}
+}
+
+// fileSchema returns the schema of a file block
+func fileSchema(sensitive bool) map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"source": {
+			Type:     schema.TypeString,
+			Optional: true,
 		},
-		"bastion_password": {
+		"content": {
 			Type:      schema.TypeString,
 			Optional:  true,
-			Sensitive: true,
+			Sensitive: sensitive,
 		},
-		"private_key": {
-			Type:      schema.TypeString,
-			Optional:  true,
-			Sensitive: true,
+		"content_base64": {
+			Description:  "Base64 encoded content of the file, use for binary content",
+			Type:         schema.TypeString,
+			Optional:     true,
+			Sensitive:    sensitive,
+			ValidateFunc: validation.StringIsBase64,
 		},
-		"host_private_key": {
-			Type:       schema.TypeString,
-			Optional:   true,
-			Sensitive:  true,
-			Deprecated: "Use 'private_key' and 'bastion_private_key'",
+		"template": {
+			Description: "Render 'source' as a Go text/template using 'vars'",
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
 		},
-		"bastion_private_key": {
-			Type:      schema.TypeString,
+		"vars": {
+			Type:      schema.TypeMap,
 			Optional:  true,
-			Sensitive: true,
-		},
-		"agent": {
-			Type:     schema.TypeBool,
-			Optional: true,
-			Default:  false,
+			Sensitive: sensitive,
+			Elem:      &schema.Schema{Type: schema.TypeString},
 		},
-		"pre_commands": {
-			Type:     schema.TypeList,
-			MaxItems: 100,
-			Optional: true,
-			Elem:     &schema.Schema{Type: schema.TypeString},
+		"destination": {
+			Type:     schema.TypeString,
+			Required: true,
 		},
-		"commands": {
-			Type:     schema.TypeList,
-			MaxItems: 100,
+		"permissions": {
+			Type:     schema.TypeString,
 			Optional: true,
-			Elem:     &schema.Schema{Type: schema.TypeString},
 		},
-		"commands_after_file_changes": {
-			Type:     schema.TypeBool,
+		"owner": {
+			Type:     schema.TypeString,
 			Optional: true,
-			Default:  true,
 		},
-		"timeout": {
+		"group": {
 			Type:     schema.TypeString,
 			Optional: true,
-			Default:  "5m",
 		},
-		"ignore_no_supported_methods_remain": {
-			Type:     schema.TypeBool,
-			Optional: true,
-			Default:  false,
+		"create_parent_dirs": {
+			Description: "Create missing parent directories of the destination",
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
 		},
-		"retry_delay": {
+		"dir_permissions": {
 			Type:     schema.TypeString,
 			Optional: true,
-			Default:  "10s",
 		},
-		"result": {
-			Type:      schema.TypeString,
-			Computed:  true,
-			Sensitive: sensitive,
+		"dir_owner": {
+			Type:     schema.TypeString,
+			Optional: true,
 		},
-		"file": {
-			Type:     schema.TypeSet,
+		"dir_group": {
+			Type:     schema.TypeString,
 			Optional: true,
-			Elem: &schema.Resource{
-				Schema: map[string]*schema.Schema{
-					"source": {
-						Type:     schema.TypeString,
-						Optional: true,
-					},
-					"content": {
-						Type:      schema.TypeString,
-						Optional:  true,
-						Sensitive: sensitive,
-					},
-					"destination": {
-						Type:     schema.TypeString,
-						Required: true,
-					},
-					"permissions": {
-						Type:     schema.TypeString,
-						Optional: true,
-					},
-					"owner": {
-						Type:     schema.TypeString,
-						Optional: true,
-					},
-					"group": {
-						Type:     schema.TypeString,
-						Optional: true,
-					},
-				},
-			},
 		},
 	}
 }
 
-func resourceResourceDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
+func hasErrors(diags diag.Diagnostics) bool {
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			return true
+		}
+	}
+	return false
+}
 
-	when := d.Get("when").(string)
+// frameworkDiagnostics converts diagnostics of the shared SDK helpers for use in framework resources
+func frameworkDiagnostics(diags diag.Diagnostics) fwdiag.Diagnostics {
+	var converted fwdiag.Diagnostics
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			converted.AddError(d.Summary, d.Detail)
+		} else {
+			converted.AddWarning(d.Summary, d.Detail)
+		}
+	}
+	return converted
+}
 
-	if when == "destroy" {
-		diags = mainRun(ctx, d, m, false)
+// sdkDiagnostics converts framework diagnostics for use in the shared SDK helpers
+func sdkDiagnostics(diags fwdiag.Diagnostics) diag.Diagnostics {
+	var converted diag.Diagnostics
+	for _, d := range diags {
+		severity := diag.Warning
+		if d.Severity() == fwdiag.SeverityError {
+			severity = diag.Error
+		}
+		converted = append(converted, diag.Diagnostic{Severity: severity, Summary: d.Summary(), Detail: d.Detail()})
 	}
-	if !hasErrors(diags) {
-		d.SetId("")
+	return converted
+}
+
+// writeOnly returns the write-only attributes by name
+func (data *sshResourceModel) writeOnly() map[string]*types.String {
+	return map[string]*types.String{
+		"password_wo":                       &data.PasswordWO,
+		"bastion_password_wo":               &data.BastionPasswordWO,
+		"private_key_wo":                    &data.PrivateKeyWO,
+		"bastion_private_key_wo":            &data.BastionPrivateKeyWO,
+		"private_key_passphrase_wo":         &data.PrivateKeyPassphraseWO,
+		"bastion_private_key_passphrase_wo": &data.BastionPrivateKeyPassphraseWO,
+	}
+}
+
+// readWriteOnly reads the write-only attributes from the configuration, they are always null in the plan
+func (data *sshResourceModel) readWriteOnly(ctx context.Context, config tfsdk.Config) fwdiag.Diagnostics {
+	var diags fwdiag.Diagnostics
+	for k, v := range data.writeOnly() {
+		diags.Append(config.GetAttribute(ctx, fwpath.Root(k), v)...)
 	}
 	return diags
 }
 
-func hasErrors(diags diag.Diagnostics) bool {
-	for _, d := range diags {
-		if d.Severity == diag.Error {
-			return true
+// clearWriteOnly nulls the write-only attributes before data is stored in state
+func (data *sshResourceModel) clearWriteOnly() {
+	for _, v := range data.writeOnly() {
+		*v = types.StringNull()
+	}
+}
+
+// valueOrWriteOnly returns the write-only value when set, the stored value otherwise
+func valueOrWriteOnly(value, writeOnly types.String) string {
+	if writeOnly.ValueString() != "" {
+		return writeOnly.ValueString()
+	}
+	return value.ValueString()
+}
+
+// resolveUnknown sets the computed attributes which were not provisioned to their prior value,
+// or to null on create
+func (data *sshResourceModel) resolveUnknown(prior *sshResourceModel) {
+	if prior == nil {
+		prior = &sshResourceModel{
+			Result:            types.StringNull(),
+			Results:           types.MapNull(types.ObjectType{AttrTypes: hostResultAttrTypes}),
+			CompletedCommands: types.Int64Null(),
+			ExecutedCommands:  types.ListNull(types.StringType),
 		}
 	}
-	return false
+	if data.Result.IsUnknown() {
+		// Commands did not run
+		data.Result = prior.Result
+	}
+	if data.Results.IsUnknown() {
+		data.Results = prior.Results
+	}
+	if data.CompletedCommands.IsUnknown() {
+		data.CompletedCommands = prior.CompletedCommands
+	}
+	if data.ExecutedCommands.IsUnknown() {
+		data.ExecutedCommands = prior.ExecutedCommands
+	}
 }
 
-func validateResource(d *schema.ResourceData) diag.Diagnostics {
-	var diags diag.Diagnostics
-	var commands []string
+// sshConfig builds the easyssh configuration from the connection arguments
+func (data *sshResourceModel) sshConfig() *easyssh.MakeConfig {
+	return connectionSettings{
+		Host:                        data.Host.ValueString(),
+		Port:                        strconv.FormatInt(data.Port.ValueInt64(), 10),
+		User:                        data.User.ValueString(),
+		HostUser:                    data.HostUser.ValueString(),
+		Password:                    valueOrWriteOnly(data.Password, data.PasswordWO),
+		PrivateKey:                  valueOrWriteOnly(data.PrivateKey, data.PrivateKeyWO),
+		HostPrivateKey:              data.HostPrivateKey.ValueString(),
+		BastionHost:                 data.BastionHost.ValueString(),
+		BastionPort:                 strconv.FormatInt(data.BastionPort.ValueInt64(), 10),
+		BastionUser:                 data.BastionUser.ValueString(),
+		BastionPassword:             valueOrWriteOnly(data.BastionPassword, data.BastionPasswordWO),
+		BastionPrivateKey:           valueOrWriteOnly(data.BastionPrivateKey, data.BastionPrivateKeyWO),
+		PrivateKeyPassphrase:        data.PrivateKeyPassphraseWO.ValueString(),
+		BastionPrivateKeyPassphrase: data.BastionPrivateKeyPassphraseWO.ValueString(),
+	}.sshConfig()
+}
 
-	timeout := d.Get("timeout").(string)
-	user := d.Get("user").(string)
-	agent := d.Get("agent").(bool)
-	privateKey := d.Get("private_key").(string)
-	hostPrivateKey := d.Get("host_private_key").(string)
-	retryDelay := d.Get("retry_delay").(string)
-	password := d.Get("password").(string)
+// retryConfig returns the retry configuration from the connection arguments
+func (data *sshResourceModel) retryConfig() (SSHRetryConfig, error) {
+	var sshRetryConfig SSHRetryConfig
+	var err error
 
-	timeoutValue, err := time.ParseDuration(timeout)
+	sshRetryConfig.timeout, err = time.ParseDuration(data.Timeout.ValueString())
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("timeout value: %w", err))
+		return sshRetryConfig, fmt.Errorf("timeout value: %w", err)
 	}
-	retryDelayValue, err := time.ParseDuration(retryDelay)
+	sshRetryConfig.retryDelay, err = time.ParseDuration(data.RetryDelay.ValueString())
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("retry_delay value: %w", err))
+		return sshRetryConfig, fmt.Errorf("retry_delay value: %w", err)
 	}
-	if retryDelayValue >= timeoutValue {
-		return diag.FromErr(fmt.Errorf("retry_delay cannot be greater than timeout (%d >= %d)", retryDelayValue, timeoutValue))
+	sshRetryConfig.ignoreUnsupportedAuthMethods = data.IgnoreNoSupportedMethodsRemain.ValueBool()
+	return sshRetryConfig, nil
+}
+
+func collectCommands(ctx context.Context, list types.List) ([]string, diag.Diagnostics) {
+	commands := make([]string, 0)
+	if diags := list.ElementsAs(ctx, &commands, false); diags.HasError() {
+		return commands, diag.Errorf("reading commands: %s", diags[0].Detail())
 	}
+	return commands, nil
+}
 
-	if len(hostPrivateKey) == 0 {
-		hostPrivateKey = privateKey
+func collectFilesToCreate(ctx context.Context, set types.Set) ([]provisionFile, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	var models []fileModel
+	if fwDiags := set.ElementsAs(ctx, &models, false); fwDiags.HasError() {
+		return nil, diag.Errorf("reading files: %s", fwDiags[0].Detail())
 	}
-	_, diags = collectFilesToCreate(d)
-	if len(diags) > 0 {
+	files := make([]provisionFile, 0)
+	for _, m := range models {
+		file, fileDiags := newProvisionFile(m.attributes())
+		if len(fileDiags) > 0 {
+			diags = append(diags, fileDiags...)
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, diags
+}
+
+// attributes returns the file block in the form used by the SDK resources
+func (f fileModel) attributes() map[string]interface{} {
+	vars := make(map[string]interface{})
+	for k, v := range f.Vars.Elements() {
+		if s, ok := v.(types.String); ok {
+			vars[k] = s.ValueString()
+		}
+	}
+	return map[string]interface{}{
+		"source":             f.Source.ValueString(),
+		"content":            f.Content.ValueString(),
+		"content_base64":     f.ContentBase64.ValueString(),
+		"destination":        f.Destination.ValueString(),
+		"permissions":        f.Permissions.ValueString(),
+		"owner":              f.Owner.ValueString(),
+		"group":              f.Group.ValueString(),
+		"template":           f.Template.ValueBool(),
+		"vars":               vars,
+		"create_parent_dirs": f.CreateParentDirs.ValueBool(),
+		"dir_permissions":    f.DirPermissions.ValueString(),
+		"dir_owner":          f.DirOwner.ValueString(),
+		"dir_group":          f.DirGroup.ValueString(),
+	}
+}
+
+// validateResource checks the arguments of data which depend on each other. It runs at plan time, where values
+// may not be known yet and checks depending on them are skipped, and again before provisioning
+func validateResource(ctx context.Context, data *sshResourceModel) fwdiag.Diagnostics {
+	var diags fwdiag.Diagnostics
+
+	if known(data.Timeout, data.RetryDelay) {
+		sshRetryConfig, err := data.retryConfig()
+		if err != nil {
+			diags.AddError("Invalid retry configuration", err.Error())
+		} else if sshRetryConfig.retryDelay >= sshRetryConfig.timeout {
+			diags.AddAttributeError(fwpath.Root("retry_delay"), "Invalid retry_delay",
+				fmt.Sprintf("retry_delay cannot be greater than timeout (%s >= %s)", sshRetryConfig.retryDelay, sshRetryConfig.timeout))
+		}
+	}
+
+	credentials := []types.String{data.PrivateKey, data.PrivateKeyWO, data.HostPrivateKey, data.Password, data.PasswordWO}
+	if !known(data.Agent) || !known(credentials...) {
 		return diags
 	}
-	commands, diags = collectCommands(d, "commands")
-	if len(diags) > 0 {
+	agent := data.Agent.ValueBool()
+	privateKey := valueOrWriteOnly(data.PrivateKey, data.PrivateKeyWO)
+	password := valueOrWriteOnly(data.Password, data.PasswordWO)
+	if agent && (data.HostPrivateKey.ValueString() != "" || privateKey != "" || password != "") {
+		diags.AddAttributeError(fwpath.Root("agent"), "Conflicting credentials",
+			"agent mode is enabled, not expecting a password or private key")
+	}
+	// The number of commands is not known until the lists are
+	if data.Commands.IsUnknown() || data.Command.IsUnknown() || data.commandsLen() == 0 {
 		return diags
 	}
-	if len(commands) > 0 {
-		if user == "" {
-			return diag.FromErr(fmt.Errorf("user must be set when 'commands' is specified"))
-		}
-		if !agent && privateKey == "" && password == "" {
-			return diag.FromErr(fmt.Errorf("'privateKey' must be set when 'commands' is specified and 'agent' is false and no 'password' is given"))
-		}
+	if known(data.User) && data.User.ValueString() == "" {
+		diags.AddAttributeError(fwpath.Root("user"), "Missing user", "user must be set when 'commands' is specified")
 	}
-	if agent && (hostPrivateKey != "" || privateKey != "" || password != "") {
-		return diag.FromErr(fmt.Errorf("agent mode is enabled, not expecting a password or private key"))
+	if !agent && privateKey == "" && password == "" {
+		diags.AddAttributeError(fwpath.Root("private_key"), "Missing credentials",
+			"'private_key' must be set when 'commands' is specified and 'agent' is false and no 'password' is given")
 	}
 	return diags
 }
 
-func mainRun(_ context.Context, d *schema.ResourceData, m interface{}, onUpdate bool) diag.Diagnostics {
-	config := m.(*Config)
+// known reports whether all values are known
+func known[T attr.Value](values ...T) bool {
+	for _, v := range values {
+		if v.IsUnknown() {
+			return false
+		}
+	}
+	return true
+}
 
-	if diags := validateResource(d); len(diags) > 0 {
+// mainRun provisions files and runs the commands of data, storing the output in its result and the
+// number of commands which completed in its completed_commands. On update prior holds the current state,
+// nothing is provisioned when files and commands did not change unless the prior apply did not complete
+// all commands. It then resumes from the first step without a checkpoint of prior, the checkpoints of
+// an apply which does not complete are stored in data
+func mainRun(ctx context.Context, data *sshResourceModel, prior *sshResourceModel, config *Config) diag.Diagnostics {
+	if config == nil {
+		config = &Config{}
+	}
+	if diags := sdkDiagnostics(validateResource(ctx, data)); len(diags) > 0 {
 		return diags
 	}
+	onUpdate := prior != nil
 
-	bastionHost := d.Get("bastion_host").(string)
-	user := d.Get("user").(string)
-	hostUser := d.Get("host_user").(string)
-	bastionUser := d.Get("bastion_user").(string)
-	password := d.Get("password").(string)
-	bastionPassword := d.Get("bastion_password").(string)
-	privateKey := d.Get("private_key").(string)
-	privateKeyPassphrase, _ := schema.EnvDefaultFunc("SSH_PRIVATE_KEY_PASSPHRASE", "")()
-	hostPrivateKey := d.Get("host_private_key").(string)
-	bastionPrivateKey := d.Get("bastion_private_key").(string)
-	bastionPrivateKeyPassphrase, _ := schema.EnvDefaultFunc("SSH_BASTION_PRIVATE_KEY_PASSPHRASE", "")()
-	host := d.Get("host").(string)
-	timeout := d.Get("timeout").(string)
-	retryDelay := d.Get("retry_delay").(string)
-	port := d.Get("port").(string)
-	bastionPort := d.Get("bastion_port").(string)
-	commandsAfterFileChanges := d.Get("commands_after_file_changes").(bool)
-
-	var sshRetryConfig SSHRetryConfig
-
-	sshRetryConfig.timeout, _ = time.ParseDuration(timeout)
-	sshRetryConfig.retryDelay, _ = time.ParseDuration(retryDelay)
-	sshRetryConfig.ignoreUnsupportedAuthMethods = d.Get("ignore_no_supported_methods_remain").(bool)
+	transferProtocol := data.TransferProtocol.ValueString()
 
-	if len(hostUser) == 0 {
-		hostUser = user
-	}
-	if len(hostPrivateKey) == 0 {
-		hostPrivateKey = privateKey
+	sshRetryConfig, err := data.retryConfig()
+	if err != nil {
+		return diag.FromErr(err)
 	}
+
 	// Pre commands
-	preCommands, diags := collectCommands(d, "pre_commands")
+	preCommands, diags := collectCommands(ctx, data.PreCommands)
 	if len(diags) > 0 {
 		return diags
 	}
 	// Fetch files first before starting provisioning
-	createFiles, diags := collectFilesToCreate(d)
+	createFiles, diags := collectFilesToCreate(ctx, data.File)
 	if len(diags) > 0 {
 		return diags
 	}
 	// And commands
-	commands, diags := collectCommands(d, "commands")
+	commands, diags := collectResourceCommands(ctx, data)
+	if len(diags) > 0 {
+		return diags
+	}
+	rollbackCommands, diags := collectCommands(ctx, data.RollbackCommands)
 	if len(diags) > 0 {
 		return diags
 	}
 
 	// Collect SSH details
-	privateIP := host
-	ssh := &easyssh.MakeConfig{
-		User:       hostUser,
-		Server:     privateIP,
-		Port:       port,
-		Key:        privateKey,
-		Passphrase: privateKeyPassphrase.(string),
-		Proxy:      http.ProxyFromEnvironment,
-		Bastion: easyssh.DefaultConfig{
-			User:       user,
-			Server:     bastionHost,
-			Passphrase: bastionPrivateKeyPassphrase.(string),
-			Port:       bastionPort,
-		},
-	}
-	if password != "" {
-		ssh.Password = password
-	}
-	if bastionPassword != "" {
-		ssh.Bastion.Password = bastionPassword
-	}
-	if bastionUser != "" {
-		ssh.Bastion.User = bastionUser
-	}
-	if hostPrivateKey != "" {
-		ssh.Key = hostPrivateKey
-	}
-	if privateKey != "" {
-		ssh.Bastion.Key = privateKey
-	}
-	if bastionPrivateKey != "" {
-		ssh.Bastion.Key = bastionPrivateKey
-	}
+	ssh := data.sshConfig()
 
-	if onUpdate && !(d.HasChange("file") || d.HasChange("commands")) {
+	retry := onUpdate && prior.completedCommands() < prior.commandsLen()
+	if onUpdate && !retry && data.File.Equal(prior.File) && data.commandsEqual(prior) {
 		return diags
 	}
+	hashes := stepHashes(data, commands)
+	start := 0
+	if retry && !data.ForceRerunAll.ValueBool() {
+		start = resumeFrom(prior.checkpoints[""], hashes)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), sshRetryConfig.timeout)
 	defer cancel()
 
-	// Run pre commands
-	if len(preCommands) > 0 {
-		_, errDiags, err := runCommands(ctx, preCommands, ssh, sshRetryConfig, m)
-		if err != nil {
-			return errDiags
+	if start == 0 {
+		// Run pre commands
+		if len(preCommands) > 0 {
+			_, errDiags, err := runCommands(ctx, preCommands, ssh, sshRetryConfig, config)
+			if err != nil {
+				return errDiags
+			}
+		}
+		// Provision files
+		if err := copyFiles(ctx, sshRetryConfig.retryDelay, ssh, transferProtocol, config, createFiles); err != nil {
+			return diag.FromErr(fmt.Errorf("copying files to remote: %w", err))
 		}
-	}
-	// Provision files
-	if err := copyFiles(ctx, sshRetryConfig.retryDelay, ssh, config, createFiles); err != nil {
-		return diag.FromErr(fmt.Errorf("copying files to remote: %w", ctx.Err()))
 	}
 
-	if onUpdate && !commandsAfterFileChanges {
-		return diags
+	// On update only the commands whose inputs changed run, a retry runs all commands after the resumed ones
+	run := make([]bool, len(commands))
+	for i := range run {
+		run[i] = true
+	}
+	if onUpdate && !retry {
+		if run, diags = commandsToRun(ctx, data, prior); len(diags) > 0 {
+			return diags
+		}
 	}
+	data.CompletedCommands = types.Int64Value(int64(start))
 
 	// Run commands
-	stdout, errDiags, err := runCommands(ctx, commands, ssh, sshRetryConfig, m)
-	if err != nil {
-		return errDiags
+	stdout, completed, executed, stepDiags := runSteps(ctx, stringOrDefault(data.OnFailure, OnFailureFail), commands, run, rollbackCommands, start, ssh, sshRetryConfig, config)
+	data.CompletedCommands = types.Int64Value(int64(completed))
+	data.ExecutedCommands = stringList(executed)
+	if completed < len(commands) {
+		data.checkpoints = checkpoints{"": newCheckpoints(hashes, completed)}
 	}
-
-	_ = d.Set("result", stdout)
-
-	return diags
-}
-
-func resourceResourceUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
-	when := d.Get("when").(string)
-
-	if when == "create" {
-		diags = mainRun(ctx, d, m, true)
+	diags = append(diags, stepDiags...)
+	if hasErrors(stepDiags) {
+		return diags
 	}
-	return diags
-}
 
-func resourceResourceRead(_ context.Context, _ *schema.ResourceData, _ interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
-
-	return diags
-}
-
-func resourceResourceCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
-
-	when := d.Get("when").(string)
-
-	if when == "create" {
-		diags = mainRun(ctx, d, m, false)
-	} else {
-		diags = validateResource(d)
+	if !onUpdate || len(executed) > 0 {
+		data.Result = types.StringValue(stdout)
 	}
 
-	if !hasErrors(diags) {
-		d.SetId(fmt.Sprintf("%d", rand.Int()))
-	}
 	return diags
 }
 
@@ -461,7 +1181,11 @@ func runCommands(ctx context.Context, commands []string, ssh *easyssh.MakeConfig
 
 	for i := 0; i < len(commands); i++ {
 		for {
-			stdout, stderr, done, err = ssh.Run(commands[i], sshRetryConfig.timeout)
+			result, attemptDone, attemptErr := runWithContext(ctx, ssh, commands[i], sshRetryConfig.timeout)
+			// An attempt abandoned on timeout has no outcome, report the previous one instead
+			if err == nil || !errors.Is(attemptErr, errWaitingForHost) {
+				stdout, stderr, done, err = result.Stdout, result.Stderr, attemptDone, attemptErr
+			}
 			_, _ = config.Debug("command: %s\ndone: %t\nstdout:\n%s\nstderr:\n%s\nerror: %v\n", commands[i], done, stdout, stderr, err)
 			if err == nil {
 				break
@@ -496,9 +1220,17 @@ func runCommands(ctx context.Context, commands []string, ssh *easyssh.MakeConfig
 	return stdout, diags, nil
 }
 
-func copyFiles(ctx context.Context, retryDelay time.Duration, ssh *easyssh.MakeConfig, config *Config, createFiles []provisionFile) error {
+func copyFiles(ctx context.Context, retryDelay time.Duration, ssh *easyssh.MakeConfig, transferProtocol string, config *Config, createFiles []provisionFile) error {
 	for _, f := range createFiles {
 		copyFile := func(f provisionFile) error {
+			mode := fileMode(f.Permissions)
+			if f.CreateParentDirs {
+				outStr, errStr, _, err := ssh.Run(parentDirsCommand(f))
+				_, _ = config.Debug("Parent directories file %s: %v %v\n", f.Destination, outStr, errStr)
+				if err != nil {
+					return err
+				}
+			}
 			if f.Source != "" {
 				src, srcErr := os.Open(f.Source)
 				if srcErr != nil {
@@ -511,12 +1243,16 @@ func copyFiles(ctx context.Context, retryDelay time.Duration, ssh *easyssh.MakeC
 					_ = src.Close()
 					return statErr
 				}
-				_ = ssh.WriteFile(src, srcStat.Size(), f.Destination)
+				if err := writeFile(ssh, transferProtocol, src, srcStat.Size(), f.Destination, mode, srcStat.ModTime()); err != nil {
+					_, _ = config.Debug("Failed to copy %s to remote file %s:%s:%s: %v\n", f.Source, ssh.Server, ssh.Port, f.Destination, err)
+					_ = src.Close()
+					return err
+				}
 				_, _ = config.Debug("Copied %s to remote file %s:%s: %d bytes\n", f.Source, ssh.Server, f.Destination, srcStat.Size())
 				_ = src.Close()
 			} else {
 				buffer := bytes.NewBufferString(f.Content)
-				if err := ssh.WriteFile(buffer, int64(buffer.Len()), f.Destination); err != nil {
+				if err := writeFile(ssh, transferProtocol, buffer, int64(buffer.Len()), f.Destination, mode, time.Time{}); err != nil {
 					_, _ = config.Debug("Failed to copy content to remote file %s:%s:%s: %v\n", ssh.Server, ssh.Port, f.Destination, err)
 					return err
 				}
@@ -548,11 +1284,17 @@ func copyFiles(ctx context.Context, retryDelay time.Duration, ssh *easyssh.MakeC
 			}
 			return nil
 		}
+		var err error
 		for {
-			err := copyFile(f)
-			if err == nil {
+			_, attemptErr := withContext(ctx, func() (struct{}, error) {
+				return struct{}{}, copyFile(f)
+			})
+			if attemptErr == nil {
 				break
 			}
+			if err == nil || !errors.Is(attemptErr, errWaitingForHost) {
+				err = attemptErr
+			}
 			select {
 			case <-time.After(retryDelay):
 			// Retry
@@ -564,81 +1306,145 @@ func copyFiles(ctx context.Context, retryDelay time.Duration, ssh *easyssh.MakeC
 	return nil
 }
 
-func collectCommands(d *schema.ResourceData, field string) ([]string, diag.Diagnostics) {
-	var diags diag.Diagnostics
-	list := d.Get(field).([]interface{})
-	commands := make([]string, 0)
-	for i := 0; i < len(list); i++ {
-		commands = append(commands, list[i].(string))
+// fileMode returns the octal permissions as a os.FileMode, defaulting to 0644
+// when permissions are not set or not in octal notation
+func fileMode(permissions string) os.FileMode {
+	mode, err := strconv.ParseUint(permissions, 8, 32)
+	if err != nil {
+		return 0644
 	}
-	return commands, diags
-
+	return os.FileMode(mode)
 }
 
 type provisionFile struct {
-	Source      string
-	Content     string
-	Destination string
-	Permissions string
-	Owner       string
-	Group       string
+	Source        string
+	Content       string
+	ContentBase64 string
+	Destination   string
+	Permissions   string
+	Owner         string
+	Group         string
+	Template      bool
+	Vars          map[string]interface{}
+
+	CreateParentDirs bool
+	DirPermissions   string
+	DirOwner         string
+	DirGroup         string
 }
 
-func collectFilesToCreate(d *schema.ResourceData) ([]provisionFile, diag.Diagnostics) {
-	var diags diag.Diagnostics
-	files := make([]provisionFile, 0)
-	if v, ok := d.GetOk("file"); ok {
-		vL := v.(*schema.Set).List()
-		for _, vi := range vL {
-			mVi := vi.(map[string]interface{})
-			file := provisionFile{
-				Source:      mVi["source"].(string),
-				Content:     mVi["content"].(string),
-				Destination: mVi["destination"].(string),
-				Permissions: mVi["permissions"].(string),
-				Owner:       mVi["owner"].(string),
-				Group:       mVi["group"].(string),
-			}
-			if file.Source == "" && file.Content == "" {
-				diags = append(diags, diag.Diagnostic{
-					Severity: diag.Error,
-					Summary:  "conflict in file block",
-					Detail:   fmt.Sprintf("file %s has neither 'source' or 'content', set one", file.Destination),
-				})
-				continue
-			}
-			if file.Source != "" && file.Content != "" {
-				diags = append(diags, diag.Diagnostic{
-					Severity: diag.Error,
-					Summary:  "conflict in file block",
-					Detail:   fmt.Sprintf("file %s has conflicting 'source' and 'content', choose only one", file.Destination),
-				})
-				continue
-			}
-			if file.Source != "" {
-				src, srcErr := os.Open(file.Source)
-				if srcErr != nil {
-					diags = append(diags, diag.Diagnostic{
-						Severity: diag.Error,
-						Summary:  "issue with source",
-						Detail:   fmt.Sprintf("file %s: %v", file.Source, srcErr),
-					})
-					continue
-				}
-				_, statErr := src.Stat()
-				if statErr != nil {
-					diags = append(diags, diag.Diagnostic{
-						Severity: diag.Error,
-						Summary:  "issue with source stat",
-						Detail:   fmt.Sprintf("file %s: %v", file.Source, statErr),
-					})
-					_ = src.Close()
-					continue
-				}
-				_ = src.Close()
-			}
-			files = append(files, file)
+// parentDirsCommand returns a shell command which creates the missing parent directories
+// of the file destination, applying the directory permissions and ownership only to
+// directories it created
+func parentDirsCommand(f provisionFile) string {
+	var dirs []string
+	for dir := path.Dir(f.Destination); dir != "/" && dir != "." && dir != ""; dir = path.Dir(dir) {
+		dirs = append([]string{shellQuote(dir)}, dirs...)
+	}
+	if len(dirs) == 0 {
+		return "true"
+	}
+	create := []string{`mkdir "$d"`}
+	if f.DirPermissions != "" {
+		create = append(create, fmt.Sprintf(`chmod %s "$d"`, shellQuote(f.DirPermissions)))
+	}
+	if f.DirOwner != "" {
+		create = append(create, fmt.Sprintf(`chown %s "$d"`, shellQuote(f.DirOwner)))
+	}
+	if f.DirGroup != "" {
+		create = append(create, fmt.Sprintf(`chgrp %s "$d"`, shellQuote(f.DirGroup)))
+	}
+	return fmt.Sprintf(`for d in %s; do if [ ! -d "$d" ]; then %s || exit 1; fi; done`, strings.Join(dirs, " "), strings.Join(create, " && "))
+}
+
+// newProvisionFile validates a single file block and resolves its content. The content of
+// 'content_base64' and of templates is decoded and rendered into Content
+func newProvisionFile(mVi map[string]interface{}) (provisionFile, diag.Diagnostics) {
+	file := provisionFile{
+		Source:        mVi["source"].(string),
+		Content:       mVi["content"].(string),
+		ContentBase64: mVi["content_base64"].(string),
+		Destination:   mVi["destination"].(string),
+		Permissions:   mVi["permissions"].(string),
+		Owner:         mVi["owner"].(string),
+		Group:         mVi["group"].(string),
+		Template:      mVi["template"].(bool),
+		Vars:          mVi["vars"].(map[string]interface{}),
+
+		CreateParentDirs: mVi["create_parent_dirs"].(bool),
+		DirPermissions:   mVi["dir_permissions"].(string),
+		DirOwner:         mVi["dir_owner"].(string),
+		DirGroup:         mVi["dir_group"].(string),
+	}
+	contentOptions := 0
+	for _, option := range []string{file.Source, file.Content, file.ContentBase64} {
+		if option != "" {
+			contentOptions++
 		}
 	}
-	return files, diags
+	if contentOptions == 0 {
+		return file, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "conflict in file block",
+			Detail:   fmt.Sprintf("file %s has neither 'source', 'content' or 'content_base64', set one", file.Destination),
+		}}
+	}
+	if contentOptions > 1 {
+		return file, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "conflict in file block",
+			Detail:   fmt.Sprintf("file %s has conflicting 'source', 'content' and 'content_base64', choose only one", file.Destination),
+		}}
+	}
+	if file.ContentBase64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(file.ContentBase64)
+		if err != nil {
+			return file, diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "issue with content_base64",
+				Detail:   fmt.Sprintf("file %s: %v", file.Destination, err),
+			}}
+		}
+		file.Content = string(decoded)
+	}
+	if file.Source != "" {
+		src, srcErr := os.Open(file.Source)
+		if srcErr != nil {
+			return file, diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "issue with source",
+				Detail:   fmt.Sprintf("file %s: %v", file.Source, srcErr),
+			}}
+		}
+		_, statErr := src.Stat()
+		if statErr != nil {
+			_ = src.Close()
+			return file, diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "issue with source stat",
+				Detail:   fmt.Sprintf("file %s: %v", file.Source, statErr),
+			}}
+		}
+		_ = src.Close()
+	}
+	if file.Template {
+		if file.Source == "" {
+			return file, diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "conflict in file block",
+				Detail:   fmt.Sprintf("file %s is a template but has no 'source'", file.Destination),
+			}}
+		}
+		rendered, err := renderTemplate(file.Source, file.Vars)
+		if err != nil {
+			return file, diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "issue with template",
+				Detail:   fmt.Sprintf("file %s: %v", file.Destination, err),
+			}}
+		}
+		file.Content = rendered
+		file.Source = ""
+	}
+	return file, nil
 }
@@ -0,0 +1,153 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	fwdiag "github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// checkpointsKey is the private state key holding the steps completed by an apply which failed
+const checkpointsKey = "checkpoints"
+
+// stepCheckpoint marks one of 'commands' as completed. Its hash covers the command together with the
+// files, pre commands and commands before it, so a step is only skipped when all of these are identical
+type stepCheckpoint struct {
+	Index int    `json:"index"`
+	Hash  string `json:"hash"`
+}
+
+// checkpoints holds the completed steps per entry of 'hosts', or at "" for 'host'
+type checkpoints map[string][]stepCheckpoint
+
+// privateState is the private resource state of a request or response
+type privateState interface {
+	GetKey(ctx context.Context, key string) ([]byte, fwdiag.Diagnostics)
+	SetKey(ctx context.Context, key string, value []byte) fwdiag.Diagnostics
+}
+
+// stepsBase returns the hash of the files and pre commands of data, which the hashes of its steps are chained from
+func stepsBase(data *sshResourceModel) [sha256.Size]byte {
+	return sha256.Sum256([]byte(data.File.String() + "\x00" + data.PreCommands.String()))
+}
+
+// stepHashes returns the hash of each of commands, chained from a hash of the files and pre commands of data
+func stepHashes(data *sshResourceModel, commands []string) []string {
+	sum := stepsBase(data)
+	hashes := make([]string, len(commands))
+	for i, command := range commands {
+		sum = sha256.Sum256(append(sum[:], command...))
+		hashes[i] = hex.EncodeToString(sum[:])
+	}
+	return hashes
+}
+
+// newCheckpoints returns the checkpoints of the first completed steps of hashes
+func newCheckpoints(hashes []string, completed int) []stepCheckpoint {
+	steps := make([]stepCheckpoint, completed)
+	for i := range steps {
+		steps[i] = stepCheckpoint{Index: i, Hash: hashes[i]}
+	}
+	return steps
+}
+
+// resumeFrom returns the index of the first step of hashes which has no matching checkpoint
+func resumeFrom(completed []stepCheckpoint, hashes []string) int {
+	n := 0
+	for n < len(completed) && n < len(hashes) && completed[n].Index == n && completed[n].Hash == hashes[n] {
+		n++
+	}
+	return n
+}
+
+func readCheckpoints(ctx context.Context, private privateState) (checkpoints, fwdiag.Diagnostics) {
+	data, diags := private.GetKey(ctx, checkpointsKey)
+	if diags.HasError() || len(data) == 0 {
+		return nil, diags
+	}
+	var cp checkpoints
+	if err := json.Unmarshal(data, &cp); err != nil {
+		// Checkpoints only save work, without them all steps run again
+		diags.AddWarning("Ignoring checkpoints", err.Error())
+		return nil, diags
+	}
+	return cp, diags
+}
+
+// writeCheckpoints stores cp in private, removing the checkpoints of an earlier apply when it is empty
+func writeCheckpoints(ctx context.Context, private privateState, cp checkpoints) fwdiag.Diagnostics {
+	if len(cp) == 0 {
+		return private.SetKey(ctx, checkpointsKey, nil)
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		var diags fwdiag.Diagnostics
+		diags.AddError("Storing checkpoints", err.Error())
+		return diags
+	}
+	return private.SetKey(ctx, checkpointsKey, data)
+}
+
+// stateCheckpoints returns the checkpoints of the commands which completed according to the state of data,
+// for when its private state is not available. With 'hosts' these are the completed commands of its results
+func stateCheckpoints(ctx context.Context, data *sshResourceModel) checkpoints {
+	commands, diags := collectResourceCommands(ctx, data)
+	if len(diags) > 0 {
+		return nil
+	}
+	hashes := stepHashes(data, commands)
+	if data.Hosts.IsNull() {
+		if data.completedCommands() > len(hashes) {
+			return nil
+		}
+		return checkpoints{"": newCheckpoints(hashes, data.completedCommands())}
+	}
+	results := make(map[string]hostResultModel)
+	if data.Results.IsNull() || data.Results.IsUnknown() || data.Results.ElementsAs(ctx, &results, false).HasError() {
+		return nil
+	}
+	cp := make(checkpoints)
+	for entry, result := range results {
+		completed := int(result.CompletedCommands.ValueInt64())
+		if result.Status.ValueString() == hostStatusFailed && completed > 0 && completed <= len(hashes) {
+			cp[entry] = newCheckpoints(hashes, completed)
+		}
+	}
+	return cp
+}
+
+// failedCreateKey identifies the progress of data by its target and the hash its steps are chained from
+func failedCreateKey(data *sshResourceModel) string {
+	base := stepsBase(data)
+	return resourceTarget(data) + "#" + hex.EncodeToString(base[:8])
+}
+
+// keepFailedCreate keeps the progress of data, whose create failed, for the create which replaces it. Terraform
+// taints such a resource and replaces it on the next apply without passing its state or private state to the
+// create, the destroy of the tainted resource runs first and hands its progress over
+func (c *Config) keepFailedCreate(data *sshResourceModel) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failedCreates == nil {
+		c.failedCreates = make(map[string]*sshResourceModel)
+	}
+	c.failedCreates[failedCreateKey(data)] = data
+}
+
+// takeFailedCreate returns the progress kept for a failed create of the target and files of data, if any
+func (c *Config) takeFailedCreate(data *sshResourceModel) *sshResourceModel {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := failedCreateKey(data)
+	prior := c.failedCreates[key]
+	delete(c.failedCreates, key)
+	return prior
+}
@@ -0,0 +1,362 @@
+package ssh
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+
+	fwdiag "github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+)
+
+// testPrivateState is an in memory privateState
+type testPrivateState map[string][]byte
+
+func (p testPrivateState) GetKey(_ context.Context, key string) ([]byte, fwdiag.Diagnostics) {
+	return p[key], nil
+}
+
+func (p testPrivateState) SetKey(_ context.Context, key string, value []byte) fwdiag.Diagnostics {
+	if len(value) == 0 {
+		delete(p, key)
+	} else {
+		p[key] = value
+	}
+	return nil
+}
+
+func TestCheckpoints_roundTrip(t *testing.T) {
+	ctx := context.Background()
+	private := make(testPrivateState)
+	want := checkpoints{"": newCheckpoints([]string{"a", "b", "c"}, 2)}
+
+	if diags := writeCheckpoints(ctx, private, want); diags.HasError() {
+		t.Fatal(diags)
+	}
+	got, diags := readCheckpoints(ctx, private)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if diags := writeCheckpoints(ctx, private, nil); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if _, ok := private[checkpointsKey]; ok {
+		t.Errorf("expected checkpoints to be removed")
+	}
+}
+
+func TestResumeFrom(t *testing.T) {
+	s := sshtest.NewServer(t)
+	commands := []string{"one", "two", "three", "four"}
+	hashes := stepHashes(testResourceModel(t, s), commands)
+	completed := newCheckpoints(hashes, 3)
+
+	if n := resumeFrom(completed, hashes); n != 3 {
+		t.Errorf("expected to resume from 3, got %d", n)
+	}
+	// A changed command changes the hash of all commands after it
+	changed := stepHashes(testResourceModel(t, s), []string{"one", "2", "three", "four"})
+	if n := resumeFrom(completed, changed); n != 1 {
+		t.Errorf("expected to resume from the changed command, got %d", n)
+	}
+	// As does a change of the files or pre commands
+	data := testResourceModel(t, s)
+	data.PreCommands, _ = types.ListValueFrom(context.Background(), types.StringType, []string{"mkdir -p /opt/app"})
+	if n := resumeFrom(completed, stepHashes(data, commands)); n != 0 {
+		t.Errorf("expected to resume from the start, got %d", n)
+	}
+}
+
+func TestMainRun_checkpoints(t *testing.T) {
+	commands := []string{"echo one", "echo two", "echo three"}
+	cases := map[string]struct {
+		commands      []string
+		forceRerunAll bool
+		wantCommands  []string
+	}{
+		"resumes from the failed command": {
+			commands:     commands,
+			wantCommands: commands[1:],
+		},
+		"resumes from a changed command": {
+			commands:     []string{"echo one", "echo 2", "echo three"},
+			wantCommands: []string{"echo 2", "echo three"},
+		},
+		"force_rerun_all": {
+			commands:      commands,
+			forceRerunAll: true,
+			wantCommands:  commands,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := sshtest.NewServer(t)
+			prior := testResourceModel(t, s, commands...)
+			prior.CompletedCommands = types.Int64Value(1)
+			prior.checkpoints = checkpoints{"": newCheckpoints(stepHashes(prior, commands), 1)}
+			data := testResourceModel(t, s, c.commands...)
+			data.ForceRerunAll = types.BoolValue(c.forceRerunAll)
+
+			if diags := mainRun(context.Background(), data, prior, newConfig(os.DevNull)); hasErrors(diags) {
+				t.Fatal(diags)
+			}
+
+			if got := s.Commands(); !reflect.DeepEqual(got, c.wantCommands) {
+				t.Errorf("expected to run %v, ran %v", c.wantCommands, got)
+			}
+			if got := data.CompletedCommands.ValueInt64(); got != 3 {
+				t.Errorf("expected all commands completed, got %d", got)
+			}
+			if len(data.checkpoints) != 0 {
+				t.Errorf("expected no checkpoints once complete, got %v", data.checkpoints)
+			}
+			if got := data.Result.ValueString(); got != "three\n" {
+				t.Errorf("expected result of the last command, got %q", got)
+			}
+		})
+	}
+}
+
+func TestMainRun_failureCheckpoints(t *testing.T) {
+	s := sshtest.NewServer(t)
+	commands := []string{"echo one", "echo two", "exit 3"}
+	data := testResourceModel(t, s, commands...)
+
+	if diags := mainRun(context.Background(), data, nil, newConfig(os.DevNull)); !hasErrors(diags) {
+		t.Fatal("expected the last command to fail")
+	}
+
+	want := checkpoints{"": newCheckpoints(stepHashes(data, commands), 2)}
+	if !reflect.DeepEqual(data.checkpoints, want) {
+		t.Errorf("expected checkpoints %v, got %v", want, data.checkpoints)
+	}
+}
+
+// testResourceServer drives ssh_resource through a configured provider server as Terraform does
+type testResourceServer struct {
+	t      *testing.T
+	server tfprotov5.ProviderServer
+	typ    tftypes.Object
+}
+
+func newTestResourceServer(t *testing.T) *testResourceServer {
+	ctx := context.Background()
+	server := providerserver.NewProtocol5(NewFrameworkProvider())()
+	schemaResp, err := server.GetProviderSchema(ctx, &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	providerType := schemaResp.Provider.ValueType().(tftypes.Object)
+	providerConfig, err := tfprotov5.NewDynamicValue(providerType, testNullAttributes(providerType, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := server.ConfigureProvider(ctx, &tfprotov5.ConfigureProviderRequest{Config: &providerConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testNoErrors(t, resp.Diagnostics)
+	return &testResourceServer{
+		t:      t,
+		server: server,
+		typ:    schemaResp.ResourceSchemas["ssh_resource"].ValueType().(tftypes.Object),
+	}
+}
+
+// testNullAttributes returns an object of typ with values, and null for its other attributes
+func testNullAttributes(typ tftypes.Object, values map[string]tftypes.Value) tftypes.Value {
+	attributes := make(map[string]tftypes.Value, len(typ.AttributeTypes))
+	for k, attributeType := range typ.AttributeTypes {
+		attributes[k] = tftypes.NewValue(attributeType, nil)
+		if v, ok := values[k]; ok {
+			attributes[k] = v
+		}
+	}
+	return tftypes.NewValue(typ, attributes)
+}
+
+func testNoErrors(t *testing.T, diags []*tfprotov5.Diagnostic) {
+	t.Helper()
+	for _, d := range diags {
+		if d.Severity == tfprotov5.DiagnosticSeverityError {
+			t.Fatalf("unexpected error: %s: %s", d.Summary, d.Detail)
+		}
+	}
+}
+
+// config returns the configuration of a resource running commands on s, with values set
+func (r *testResourceServer) config(s *sshtest.Server, values map[string]tftypes.Value, commands ...string) tftypes.Value {
+	list := make([]tftypes.Value, len(commands))
+	for i, command := range commands {
+		list[i] = tftypes.NewValue(tftypes.String, command)
+	}
+	config := map[string]tftypes.Value{
+		"host":        tftypes.NewValue(tftypes.String, s.Host),
+		"port":        tftypes.NewValue(tftypes.Number, testPort(r.t, s)),
+		"user":        tftypes.NewValue(tftypes.String, s.User),
+		"password":    tftypes.NewValue(tftypes.String, s.Password),
+		"timeout":     tftypes.NewValue(tftypes.String, testTimeout.String()),
+		"retry_delay": tftypes.NewValue(tftypes.String, testRetryDelay.String()),
+		"commands":    tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, list),
+		"command":     tftypes.NewValue(r.typ.AttributeTypes["command"], []tftypes.Value{}),
+		"file":        tftypes.NewValue(r.typ.AttributeTypes["file"], []tftypes.Value{}),
+	}
+	for k, v := range values {
+		config[k] = v
+	}
+	return testNullAttributes(r.typ, config)
+}
+
+// create plans and applies the creation of a resource with config, it returns the response of the apply
+func (r *testResourceServer) create(config tftypes.Value) *tfprotov5.ApplyResourceChangeResponse {
+	r.t.Helper()
+	ctx := context.Background()
+	prior := r.dynamicValue(tftypes.NewValue(r.typ, nil))
+	proposed := r.dynamicValue(config)
+	plan, err := r.server.PlanResourceChange(ctx, &tfprotov5.PlanResourceChangeRequest{
+		TypeName:         "ssh_resource",
+		PriorState:       &prior,
+		ProposedNewState: &proposed,
+		Config:           &proposed,
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	testNoErrors(r.t, plan.Diagnostics)
+	resp, err := r.server.ApplyResourceChange(ctx, &tfprotov5.ApplyResourceChangeRequest{
+		TypeName:       "ssh_resource",
+		PriorState:     &prior,
+		PlannedState:   plan.PlannedState,
+		Config:         &proposed,
+		PlannedPrivate: plan.PlannedPrivate,
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return resp
+}
+
+// replace destroys the resource with state and creates it again with config, as Terraform replaces a tainted
+// resource. The destroy is passed the private state planned for the create, not the one of the tainted resource
+func (r *testResourceServer) replace(state *tfprotov5.DynamicValue, config tftypes.Value) *tfprotov5.ApplyResourceChangeResponse {
+	r.t.Helper()
+	planned := r.dynamicValue(tftypes.NewValue(r.typ, nil))
+	resp, err := r.server.ApplyResourceChange(context.Background(), &tfprotov5.ApplyResourceChangeRequest{
+		TypeName:     "ssh_resource",
+		PriorState:   state,
+		PlannedState: &planned,
+		Config:       &planned,
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	testNoErrors(r.t, resp.Diagnostics)
+	return r.create(config)
+}
+
+func (r *testResourceServer) dynamicValue(value tftypes.Value) tfprotov5.DynamicValue {
+	v, err := tfprotov5.NewDynamicValue(r.typ, value)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return v
+}
+
+// attribute returns attribute k of state
+func (r *testResourceServer) attribute(state *tfprotov5.DynamicValue, k string) tftypes.Value {
+	value, err := state.Unmarshal(r.typ)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	var attributes map[string]tftypes.Value
+	if err := value.As(&attributes); err != nil {
+		r.t.Fatal(err)
+	}
+	return attributes[k]
+}
+
+func TestSSHResource_failedCreateResumes(t *testing.T) {
+	s := sshtest.NewServer(t)
+	r := newTestResourceServer(t)
+	commands := []string{"echo one", "echo two", "echo three"}
+	config := r.config(s, nil, commands...)
+
+	s.SetFaults(sshtest.Faults{Command: sshtest.ExitWith("echo two", 1)})
+	resp := r.create(config)
+	if len(resp.Diagnostics) == 0 || resp.Diagnostics[0].Severity != tfprotov5.DiagnosticSeverityError {
+		t.Fatalf("expected the create to fail, got %v", resp.Diagnostics)
+	}
+	if got := r.attribute(resp.NewState, "completed_commands"); !got.Equal(tftypes.NewValue(tftypes.Number, 1)) {
+		t.Fatalf("expected one completed command, got %v", got)
+	}
+
+	// The next apply replaces the tainted resource and resumes from the failed command
+	s.SetFaults(sshtest.Faults{})
+	ran := len(s.Commands())
+	resp = r.replace(resp.NewState, config)
+	testNoErrors(t, resp.Diagnostics)
+
+	// A retry of the failed command may still reach the server after the failed create gave up
+	got := s.Commands()[ran:]
+	if slices.Contains(got, commands[0]) || len(got) < 2 || !reflect.DeepEqual(got[len(got)-2:], commands[1:]) {
+		t.Errorf("expected to run %v, ran %v", commands[1:], got)
+	}
+	if got := r.attribute(resp.NewState, "completed_commands"); !got.Equal(tftypes.NewValue(tftypes.Number, 3)) {
+		t.Errorf("expected all commands completed, got %v", got)
+	}
+	if got := r.attribute(resp.NewState, "result"); !got.Equal(tftypes.NewValue(tftypes.String, "three\n")) {
+		t.Errorf("expected result of the last command, got %v", got)
+	}
+
+	// A later replace provisions everything again
+	ran = len(s.Commands())
+	resp = r.replace(resp.NewState, config)
+	testNoErrors(t, resp.Diagnostics)
+	if got := s.Commands()[ran:]; !reflect.DeepEqual(got, commands) {
+		t.Errorf("expected to run %v, ran %v", commands, got)
+	}
+}
+
+func TestSSHResource_failedCreateResumes_hosts(t *testing.T) {
+	servers := []*sshtest.Server{sshtest.NewServer(t), sshtest.NewServer(t)}
+	r := newTestResourceServer(t)
+	commands := []string{"echo one", "echo two", "echo three"}
+	config := r.config(servers[0], map[string]tftypes.Value{
+		"host": tftypes.NewValue(tftypes.String, nil),
+		"hosts": tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, []tftypes.Value{
+			tftypes.NewValue(tftypes.String, servers[0].Address()),
+			tftypes.NewValue(tftypes.String, servers[1].Address()),
+		}),
+	}, commands...)
+
+	servers[1].SetFaults(sshtest.Faults{Command: sshtest.ExitWith("echo two", 1)})
+	resp := r.create(config)
+	if len(resp.Diagnostics) == 0 || resp.Diagnostics[len(resp.Diagnostics)-1].Severity != tfprotov5.DiagnosticSeverityError {
+		t.Fatalf("expected the create to fail, got %v", resp.Diagnostics)
+	}
+
+	// The next apply replaces the tainted resource, which resumes the failed host from the failed command
+	servers[1].SetFaults(sshtest.Faults{})
+	ran := []int{len(servers[0].Commands()), len(servers[1].Commands())}
+	resp = r.replace(resp.NewState, config)
+	testNoErrors(t, resp.Diagnostics)
+
+	if got := servers[0].Commands()[ran[0]:]; len(got) > 0 {
+		t.Errorf("expected the provisioned host not to run commands, ran %v", got)
+	}
+	got := servers[1].Commands()[ran[1]:]
+	if slices.Contains(got, commands[0]) || len(got) < 2 || !reflect.DeepEqual(got[len(got)-2:], commands[1:]) {
+		t.Errorf("expected the failed host to run %v, ran %v", commands[1:], got)
+	}
+}
@@ -0,0 +1,197 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	fwdiag "github.com/hashicorp/terraform-plugin-framework/diag"
+	fwpath "github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// commandModel is a command block, a command with the inputs which make it run again on update
+type commandModel struct {
+	Run      types.String `tfsdk:"run"`
+	Triggers types.Map    `tfsdk:"triggers"`
+	RunOn    types.List   `tfsdk:"run_on"`
+}
+
+// commandBlocks reports whether the commands of data are given as command blocks
+func (data *sshResourceModel) commandBlocks() bool {
+	return len(data.Command.Elements()) > 0
+}
+
+// commandsLen returns the number of commands of data, given either by 'commands' or command blocks
+func (data *sshResourceModel) commandsLen() int {
+	if data.commandBlocks() {
+		return len(data.Command.Elements())
+	}
+	return len(data.Commands.Elements())
+}
+
+// commandsEqual reports whether data has the same commands as other
+func (data *sshResourceModel) commandsEqual(other *sshResourceModel) bool {
+	return data.Commands.Equal(other.Commands) && data.Command.Equal(other.Command)
+}
+
+// collectResourceCommands returns the commands of data, given either by 'commands' or command blocks
+func collectResourceCommands(ctx context.Context, data *sshResourceModel) ([]string, diag.Diagnostics) {
+	if !data.commandBlocks() {
+		return collectCommands(ctx, data.Commands)
+	}
+	var blocks []commandModel
+	if fwDiags := data.Command.ElementsAs(ctx, &blocks, false); fwDiags.HasError() {
+		return nil, diag.Errorf("reading command blocks: %s", fwDiags[0].Detail())
+	}
+	commands := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		commands = append(commands, b.Run.ValueString())
+	}
+	return commands, nil
+}
+
+// commandsToRun returns for each command of data whether it runs on update from prior. All of 'commands'
+// run when 'commands_after_file_changes' is set. A command block runs when no block of prior has the same
+// command and triggers, or when a file it runs on changed
+func commandsToRun(ctx context.Context, data *sshResourceModel, prior *sshResourceModel) ([]bool, diag.Diagnostics) {
+	run := make([]bool, data.commandsLen())
+	if !data.commandBlocks() {
+		for i := range run {
+			run[i] = data.CommandsAfterFileChanges.ValueBool()
+		}
+		return run, nil
+	}
+	var blocks, priorBlocks []commandModel
+	if fwDiags := data.Command.ElementsAs(ctx, &blocks, false); fwDiags.HasError() {
+		return nil, diag.Errorf("reading command blocks: %s", fwDiags[0].Detail())
+	}
+	if fwDiags := prior.Command.ElementsAs(ctx, &priorBlocks, false); fwDiags.HasError() {
+		return nil, diag.Errorf("reading command blocks: %s", fwDiags[0].Detail())
+	}
+	changed := changedDestinations(data.File, prior.File)
+	for i, b := range blocks {
+		run[i] = true
+		for _, p := range priorBlocks {
+			if b.Run.Equal(p.Run) && b.Triggers.Equal(p.Triggers) {
+				run[i] = false
+				break
+			}
+		}
+		for _, destination := range b.RunOn.Elements() {
+			if changed[destination.(types.String).ValueString()] {
+				run[i] = true
+			}
+		}
+	}
+	return run, nil
+}
+
+// planExecutedCommands returns the commands which run in the apply of plan, or nil when the state
+// value is kept. They are unknown when the apply resumes or not all inputs are known yet
+func planExecutedCommands(ctx context.Context, plan *sshResourceModel, state *sshResourceModel, changed, incomplete bool) (*types.List, fwdiag.Diagnostics) {
+	unknown := types.ListUnknown(types.StringType)
+	switch {
+	case !plan.Hosts.IsNull() || plan.When.ValueString() == "destroy":
+		null := types.ListNull(types.StringType)
+		return &null, nil
+	case incomplete:
+		return &unknown, nil
+	case state != nil && !changed:
+		return nil, nil
+	}
+	for _, v := range []attr.Value{plan.Commands, plan.Command, plan.File, plan.CommandsAfterFileChanges} {
+		if !fullyKnown(ctx, v) {
+			return &unknown, nil
+		}
+	}
+	commands, diags := collectResourceCommands(ctx, plan)
+	if len(diags) > 0 {
+		return nil, frameworkDiagnostics(diags)
+	}
+	var executed []string
+	if state == nil {
+		executed = commands
+	} else {
+		run, diags := commandsToRun(ctx, plan, state)
+		if len(diags) > 0 {
+			return nil, frameworkDiagnostics(diags)
+		}
+		for i, command := range commands {
+			if run[i] {
+				executed = append(executed, command)
+			}
+		}
+	}
+	list := stringList(executed)
+	return &list, nil
+}
+
+// validateCommandBlocks checks the 'run_on' entries of command blocks are destinations of file blocks
+func validateCommandBlocks(ctx context.Context, data *sshResourceModel) fwdiag.Diagnostics {
+	var diags fwdiag.Diagnostics
+	if !fullyKnown(ctx, data.Command) || !fullyKnown(ctx, data.File) {
+		return diags
+	}
+	var blocks []commandModel
+	if diags = data.Command.ElementsAs(ctx, &blocks, false); diags.HasError() {
+		return diags
+	}
+	destinations := fileDestinations(data.File)
+	for i, b := range blocks {
+		for j, v := range b.RunOn.Elements() {
+			destination := v.(types.String).ValueString()
+			if _, ok := destinations[destination]; !ok {
+				diags.AddAttributeError(fwpath.Root("command").AtListIndex(i).AtName("run_on").AtListIndex(j),
+					"Unknown file destination", fmt.Sprintf("No file block has destination '%s'", destination))
+			}
+		}
+	}
+	return diags
+}
+
+// stringList returns values as a list, which is empty rather than null when there are none
+func stringList(values []string) types.List {
+	elems := make([]attr.Value, 0, len(values))
+	for _, v := range values {
+		elems = append(elems, types.StringValue(v))
+	}
+	return types.ListValueMust(types.StringType, elems)
+}
+
+// fullyKnown reports whether v and all values nested in it are known
+func fullyKnown(ctx context.Context, v attr.Value) bool {
+	tfValue, err := v.ToTerraformValue(ctx)
+	return err == nil && tfValue.IsFullyKnown()
+}
+
+// fileDestinations returns the file blocks of set by their destination
+func fileDestinations(set types.Set) map[string]types.Object {
+	m := make(map[string]types.Object)
+	for _, v := range set.Elements() {
+		if file, ok := v.(types.Object); ok {
+			if destination, ok := file.Attributes()["destination"].(types.String); ok {
+				m[destination.ValueString()] = file
+			}
+		}
+	}
+	return m
+}
+
+// changedDestinations returns the destinations of the file blocks which were added, changed or removed
+func changedDestinations(files, priorFiles types.Set) map[string]bool {
+	current, prior := fileDestinations(files), fileDestinations(priorFiles)
+	changed := make(map[string]bool)
+	for destination, file := range current {
+		if p, ok := prior[destination]; !ok || !file.Equal(p) {
+			changed[destination] = true
+		}
+	}
+	for destination := range prior {
+		if _, ok := current[destination]; !ok {
+			changed[destination] = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,193 @@
+package ssh
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+)
+
+// testCommandBlocks returns command blocks for data
+func testCommandBlocks(t *testing.T, blocks ...commandModel) types.List {
+	for i := range blocks {
+		if blocks[i].Triggers.IsNull() {
+			blocks[i].Triggers = types.MapNull(types.StringType)
+		}
+		if blocks[i].RunOn.IsNull() {
+			blocks[i].RunOn = types.ListNull(types.StringType)
+		}
+	}
+	elemType := sshResourceSchema(false).Blocks["command"].Type().(types.ListType).ElemType
+	list, diags := types.ListValueFrom(context.Background(), elemType, blocks)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	return list
+}
+
+// testFiles returns file blocks for data with the given content by destination
+func testFiles(t *testing.T, content map[string]string) types.Set {
+	var files []fileModel
+	for destination, c := range content {
+		files = append(files, fileModel{
+			Content:     types.StringValue(c),
+			Destination: types.StringValue(destination),
+			Vars:        types.MapNull(types.StringType),
+		})
+	}
+	elemType := sshResourceSchema(false).Blocks["file"].Type().(types.SetType).ElemType
+	set, diags := types.SetValueFrom(context.Background(), elemType, files)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	return set
+}
+
+func TestCommandsToRun(t *testing.T) {
+	ctx := context.Background()
+	restart := commandModel{
+		Run:   types.StringValue("systemctl restart app"),
+		RunOn: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("/etc/app.conf")}),
+	}
+	migrate := commandModel{
+		Run:      types.StringValue("app migrate"),
+		Triggers: types.MapValueMust(types.StringType, map[string]attr.Value{"version": types.StringValue("1")}),
+	}
+	migrateV2 := migrate
+	migrateV2.Triggers = types.MapValueMust(types.StringType, map[string]attr.Value{"version": types.StringValue("2")})
+	banner := commandModel{Run: types.StringValue("echo done")}
+
+	cases := map[string]struct {
+		prior, data []commandModel
+		priorFiles  map[string]string
+		files       map[string]string
+		want        []bool
+	}{
+		"unchanged": {
+			prior: []commandModel{restart, migrate, banner},
+			data:  []commandModel{restart, migrate, banner},
+			want:  []bool{false, false, false},
+		},
+		"file run on changed": {
+			prior:      []commandModel{restart, migrate, banner},
+			data:       []commandModel{restart, migrate, banner},
+			priorFiles: map[string]string{"/etc/app.conf": "a", "/etc/other.conf": "a"},
+			files:      map[string]string{"/etc/app.conf": "b", "/etc/other.conf": "a"},
+			want:       []bool{true, false, false},
+		},
+		"other file changed": {
+			prior:      []commandModel{restart, migrate, banner},
+			data:       []commandModel{restart, migrate, banner},
+			priorFiles: map[string]string{"/etc/app.conf": "a", "/etc/other.conf": "a"},
+			files:      map[string]string{"/etc/app.conf": "a", "/etc/other.conf": "b"},
+			want:       []bool{false, false, false},
+		},
+		"triggers changed": {
+			prior: []commandModel{restart, migrate, banner},
+			data:  []commandModel{restart, migrateV2, banner},
+			want:  []bool{false, true, false},
+		},
+		"command added": {
+			prior: []commandModel{restart, banner},
+			data:  []commandModel{restart, migrate, banner},
+			want:  []bool{false, true, false},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := sshtest.NewServer(t)
+			prior := testResourceModel(t, s)
+			prior.Command = testCommandBlocks(t, c.prior...)
+			prior.File = testFiles(t, c.priorFiles)
+			data := testResourceModel(t, s)
+			data.Command = testCommandBlocks(t, c.data...)
+			data.File = testFiles(t, c.files)
+
+			got, diags := commandsToRun(ctx, data, prior)
+			if len(diags) > 0 {
+				t.Fatal(diags)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestCommandsToRun_commands(t *testing.T) {
+	s := sshtest.NewServer(t)
+	for _, after := range []bool{true, false} {
+		prior := testResourceModel(t, s, "one", "two")
+		data := testResourceModel(t, s, "one", "three")
+		data.CommandsAfterFileChanges = types.BoolValue(after)
+
+		got, diags := commandsToRun(context.Background(), data, prior)
+		if len(diags) > 0 {
+			t.Fatal(diags)
+		}
+		if want := []bool{after, after}; !reflect.DeepEqual(got, want) {
+			t.Errorf("commands_after_file_changes %t: expected %v, got %v", after, want, got)
+		}
+	}
+}
+
+func TestMainRun_commandBlocks(t *testing.T) {
+	ctx := context.Background()
+	s := sshtest.NewServer(t)
+	blocks := []commandModel{
+		{Run: types.StringValue("cat app.conf"), RunOn: types.ListValueMust(types.StringType, []attr.Value{types.StringValue(s.Path("app.conf"))})},
+		{Run: types.StringValue("echo migrated")},
+	}
+	prior := testResourceModel(t, s)
+	prior.Command = testCommandBlocks(t, blocks...)
+	prior.File = testFiles(t, map[string]string{s.Path("app.conf"): "v1\n"})
+	data := testResourceModel(t, s)
+	data.Command = testCommandBlocks(t, blocks...)
+	data.File = testFiles(t, map[string]string{s.Path("app.conf"): "v2\n"})
+
+	planned, diags := planExecutedCommands(ctx, data, prior, true, false)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	if diags := mainRun(ctx, data, prior, newConfig(os.DevNull)); hasErrors(diags) {
+		t.Fatal(diags)
+	}
+
+	want := []string{"cat app.conf"}
+	var got []string
+	for _, command := range s.Commands() {
+		// Files are copied as well
+		if !strings.HasPrefix(command, "scp ") {
+			got = append(got, command)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected to run %v, ran %v", want, got)
+	}
+	if !data.ExecutedCommands.Equal(stringList(want)) || !planned.Equal(data.ExecutedCommands) {
+		t.Errorf("expected executed commands %v as planned, got %v planned %v", want, data.ExecutedCommands, planned)
+	}
+	if got := data.Result.ValueString(); got != "v2\n" {
+		t.Errorf("expected result of the changed command, got %q", got)
+	}
+}
+
+func TestValidateCommandBlocks(t *testing.T) {
+	s := sshtest.NewServer(t)
+	data := testResourceModel(t, s)
+	data.File = testFiles(t, map[string]string{"/etc/app.conf": "a"})
+	data.Command = testCommandBlocks(t,
+		commandModel{Run: types.StringValue("a"), RunOn: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("/etc/app.conf")})},
+		commandModel{Run: types.StringValue("b"), RunOn: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("/etc/missing.conf")})},
+	)
+
+	diags := validateCommandBlocks(context.Background(), data)
+	if len(diags) != 1 || diags[0].Summary() != "Unknown file destination" {
+		t.Errorf("expected the unknown destination to be reported, got %v", diags)
+	}
+}
@@ -0,0 +1,189 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+)
+
+func TestParentDirsCommand(t *testing.T) {
+	cases := map[string]struct {
+		file provisionFile
+		want string
+	}{
+		"root-level destination": {
+			file: provisionFile{Destination: "/app.conf"},
+			want: "true",
+		},
+		"relative destination": {
+			file: provisionFile{Destination: "app.conf"},
+			want: "true",
+		},
+		"nested directories": {
+			file: provisionFile{Destination: "/opt/my app/conf.d/app.conf"},
+			want: `for d in '/opt' '/opt/my app' '/opt/my app/conf.d'; do if [ ! -d "$d" ]; then mkdir "$d" || exit 1; fi; done`,
+		},
+		"quotes in the path": {
+			file: provisionFile{Destination: "/opt/it's/app.conf"},
+			want: `for d in '/opt' '/opt/it'\''s'; do if [ ! -d "$d" ]; then mkdir "$d" || exit 1; fi; done`,
+		},
+		"permissions and ownership": {
+			file: provisionFile{Destination: "/opt/app/app.conf", DirPermissions: "0750", DirOwner: "app", DirGroup: "staff"},
+			want: `for d in '/opt' '/opt/app'; do if [ ! -d "$d" ]; then mkdir "$d" && chmod '0750' "$d" && chown 'app' "$d" && chgrp 'staff' "$d" || exit 1; fi; done`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := parentDirsCommand(c.file); got != c.want {
+				t.Errorf("expected\n%s\ngot\n%s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestParentDirsCommand_run(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh available")
+	}
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "existing"), 0700); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f := provisionFile{Destination: filepath.Join(root, "existing", "my app", "$(touch pwned)", "app.conf"), DirPermissions: "0750"}
+	cmd := exec.Command(sh, "-c", parentDirsCommand(f))
+	cmd.Dir = root
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("err: %v: %s", err, output)
+	}
+
+	created := filepath.Dir(f.Destination)
+	info, err := os.Stat(created)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0750 {
+		t.Errorf("expected created directory mode 0750, got %o", mode)
+	}
+	if info, err := os.Stat(filepath.Join(root, "existing")); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("expected the existing directory to keep mode 0700, got %v %v", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "pwned")); !os.IsNotExist(err) {
+		t.Errorf("expected the path not to be evaluated by the shell")
+	}
+}
+
+func testFileBlock(values map[string]interface{}) map[string]interface{} {
+	block := map[string]interface{}{
+		"source":             "",
+		"content":            "",
+		"content_base64":     "",
+		"destination":        "/tmp/app.bin",
+		"permissions":        "",
+		"owner":              "",
+		"group":              "",
+		"template":           false,
+		"vars":               map[string]interface{}{},
+		"create_parent_dirs": false,
+		"dir_permissions":    "",
+		"dir_owner":          "",
+		"dir_group":          "",
+	}
+	for k, v := range values {
+		block[k] = v
+	}
+	return block
+}
+
+func TestNewProvisionFile(t *testing.T) {
+	binary := []byte{0x00, 0xff, 0x7f, 0x80, '\n', 0x1b, 0xfe}
+	source := filepath.Join(t.TempDir(), "app.conf")
+	if err := os.WriteFile(source, []byte("listen: 8080\n"), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := map[string]struct {
+		block       map[string]interface{}
+		wantContent string
+		wantError   string
+	}{
+		"content": {
+			block:       map[string]interface{}{"content": "hello"},
+			wantContent: "hello",
+		},
+		"binary content_base64": {
+			block:       map[string]interface{}{"content_base64": base64.StdEncoding.EncodeToString(binary)},
+			wantContent: string(binary),
+		},
+		"invalid content_base64": {
+			block:     map[string]interface{}{"content_base64": "not base64!"},
+			wantError: "issue with content_base64",
+		},
+		"content and content_base64": {
+			block:     map[string]interface{}{"content": "hello", "content_base64": "aGVsbG8="},
+			wantError: "conflict in file block",
+		},
+		"source and content_base64": {
+			block:     map[string]interface{}{"source": source, "content_base64": "aGVsbG8="},
+			wantError: "conflict in file block",
+		},
+		"no content": {
+			block:     map[string]interface{}{},
+			wantError: "conflict in file block",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			file, diags := newProvisionFile(testFileBlock(c.block))
+			if c.wantError != "" {
+				if !hasErrors(diags) || diags[0].Summary != c.wantError {
+					t.Fatalf("expected error %q, got %v", c.wantError, diags)
+				}
+				return
+			}
+			if hasErrors(diags) {
+				t.Fatalf("unexpected error: %v", diags)
+			}
+			if file.Content != c.wantContent {
+				t.Errorf("expected content %q, got %q", c.wantContent, file.Content)
+			}
+		})
+	}
+}
+
+func TestNewProvisionFile_binaryRoundTrip(t *testing.T) {
+	binary := make([]byte, 256)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+	for _, protocol := range []string{TransferProtocolSCP, TransferProtocolSFTP} {
+		t.Run(protocol, func(t *testing.T) {
+			s := sshtest.NewServer(t)
+			file, diags := newProvisionFile(testFileBlock(map[string]interface{}{
+				"content_base64": base64.StdEncoding.EncodeToString(binary),
+				"destination":    s.Path("app.bin"),
+			}))
+			if hasErrors(diags) {
+				t.Fatalf("unexpected error: %v", diags)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+			if err := copyFiles(ctx, testRetryDelay, testServerSSHConfig(s), protocol, newConfig(os.DevNull), []provisionFile{file}); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			got, err := os.ReadFile(s.Path("app.bin"))
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !bytes.Equal(got, binary) {
+				t.Errorf("expected the binary content to be copied unchanged, got %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+const (
+	StrategyParallel = "parallel"
+	StrategySerial   = "serial"
+	StrategyRolling  = "rolling"
+)
+
+const (
+	hostStatusSuccess = "success"
+	hostStatusFailed  = "failed"
+	hostStatusSkipped = "skipped"
+)
+
+// hostResultModel is the outcome of provisioning one of the hosts of a resource
+type hostResultModel struct {
+	Stdout            types.String `tfsdk:"stdout"`
+	Status            types.String `tfsdk:"status"`
+	CompletedCommands types.Int64  `tfsdk:"completed_commands"`
+}
+
+var hostResultAttrTypes = map[string]attr.Type{
+	"stdout":             types.StringType,
+	"status":             types.StringType,
+	"completed_commands": types.Int64Type,
+}
+
+// provision runs mainRun for the host of data, or for each of its hosts when 'hosts' is set
+func provision(ctx context.Context, data *sshResourceModel, prior *sshResourceModel, config *Config) diag.Diagnostics {
+	if !data.Hosts.IsNull() {
+		return runHosts(ctx, data, prior, config)
+	}
+	return mainRun(ctx, data, prior, config)
+}
+
+// hostBatches splits hosts into batches which run one after another, the hosts of a batch run concurrently
+func hostBatches(hosts []string, strategy string, batchSize int) [][]string {
+	switch strategy {
+	case StrategySerial:
+		batchSize = 1
+	case StrategyRolling:
+		if batchSize < 1 {
+			batchSize = 1
+		}
+	default:
+		batchSize = len(hosts)
+	}
+	var batches [][]string
+	for len(hosts) > 0 {
+		n := min(batchSize, len(hosts))
+		batches = append(batches, hosts[:n])
+		hosts = hosts[n:]
+	}
+	return batches
+}
+
+// exceedsMaxFail reports whether more than maxFailPercentage of total hosts failed
+func exceedsMaxFail(failed, total int, maxFailPercentage int64) bool {
+	return int64(failed)*100 > maxFailPercentage*int64(total)
+}
+
+// runHosts runs mainRun for each of the hosts of data in the batches of its strategy and stores
+// the outcome per host in its results. Batches are not started once more than max_fail_percentage
+// of the hosts failed, failures within that percentage are reported as warnings.
+// On update prior holds the current state, hosts which were added since are provisioned as on create
+func runHosts(ctx context.Context, data *sshResourceModel, prior *sshResourceModel, config *Config) diag.Diagnostics {
+	var hosts []string
+	if fwDiags := data.Hosts.ElementsAs(ctx, &hosts, false); fwDiags.HasError() {
+		return diag.Errorf("reading hosts: %s", fwDiags[0].Detail())
+	}
+	priorResults := make(map[string]hostResultModel)
+	if prior != nil && !prior.Results.IsNull() {
+		if fwDiags := prior.Results.ElementsAs(ctx, &priorResults, false); fwDiags.HasError() {
+			return diag.Errorf("reading results: %s", fwDiags[0].Detail())
+		}
+	}
+	strategy := stringOrDefault(data.Strategy, StrategyParallel)
+	batchSize := int(data.BatchSize.ValueInt64())
+	maxFailPercentage := data.MaxFailPercentage.ValueInt64()
+
+	var mu sync.Mutex
+	var hostDiags diag.Diagnostics
+	results := make(map[string]hostResultModel, len(hosts))
+	hostCheckpoints := make(checkpoints)
+	failed := 0
+	for _, batch := range hostBatches(hosts, strategy, batchSize) {
+		if exceedsMaxFail(failed, len(hosts), maxFailPercentage) {
+			for _, host := range batch {
+				results[host] = hostResultModel{Stdout: types.StringNull(), Status: types.StringValue(hostStatusSkipped), CompletedCommands: types.Int64Null()}
+			}
+			continue
+		}
+		var wg sync.WaitGroup
+		for _, host := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, steps, diags := runHost(ctx, data, prior, priorResults, host, config)
+				mu.Lock()
+				defer mu.Unlock()
+				results[host] = result
+				if len(steps) > 0 {
+					hostCheckpoints[host] = steps
+				}
+				if hasErrors(diags) {
+					failed++
+				}
+				for _, d := range diags {
+					d.Summary = fmt.Sprintf("%s: %s", host, d.Summary)
+					hostDiags = append(hostDiags, d)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	resultsValue, fwDiags := types.MapValueFrom(ctx, types.ObjectType{AttrTypes: hostResultAttrTypes}, results)
+	if fwDiags.HasError() {
+		return diag.Errorf("storing results: %s", fwDiags[0].Detail())
+	}
+	data.Results = resultsValue
+	// Progress is tracked per host through its status and checkpoints
+	data.CompletedCommands = types.Int64Null()
+	data.ExecutedCommands = types.ListNull(types.StringType)
+	data.checkpoints = hostCheckpoints
+
+	if !exceedsMaxFail(failed, len(hosts), maxFailPercentage) {
+		for i := range hostDiags {
+			hostDiags[i].Severity = diag.Warning
+		}
+		return hostDiags
+	}
+	return append(hostDiags, diag.Diagnostic{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("%d of %d hosts failed, more than max_fail_percentage (%d%%)", failed, len(hosts), maxFailPercentage),
+	})
+}
+
+// hostsIncomplete reports whether a host in the results of data did not succeed. Such hosts are
+// provisioned again on update
+func (data *sshResourceModel) hostsIncomplete() bool {
+	if data.Results.IsNull() || data.Results.IsUnknown() {
+		return false
+	}
+	for _, v := range data.Results.Elements() {
+		result, ok := v.(types.Object)
+		if !ok {
+			continue
+		}
+		if status, ok := result.Attributes()["status"].(types.String); !ok || status.ValueString() != hostStatusSuccess {
+			return true
+		}
+	}
+	return false
+}
+
+// runHost runs mainRun for a single entry of 'hosts', which may include a port. It returns the
+// checkpoints of the host when its commands did not complete
+func runHost(ctx context.Context, data *sshResourceModel, prior *sshResourceModel, priorResults map[string]hostResultModel, entry string, config *Config) (hostResultModel, []stepCheckpoint, diag.Diagnostics) {
+	host, port, err := splitHostsEntry(entry, data.Port.ValueInt64())
+	if err != nil {
+		return hostResultModel{Stdout: types.StringNull(), Status: types.StringValue(hostStatusFailed), CompletedCommands: types.Int64Value(0)}, nil, diag.FromErr(err)
+	}
+	hostData := *data
+	hostData.Host = types.StringValue(host)
+	hostData.Port = types.Int64Value(port)
+	hostData.Result = types.StringUnknown()
+	hostData.checkpoints = nil
+
+	// Hosts which did not succeed before are provisioned as on create, or resumed from their checkpoints
+	var hostPrior *sshResourceModel
+	priorResult, ok := priorResults[entry]
+	var steps []stepCheckpoint
+	if prior != nil {
+		steps = prior.checkpoints[entry]
+	}
+	if ok && (priorResult.Status.ValueString() == hostStatusSuccess || len(steps) > 0) {
+		p := *prior
+		p.Host = hostData.Host
+		p.Port = hostData.Port
+		p.CompletedCommands = types.Int64Null()
+		if priorResult.Status.ValueString() != hostStatusSuccess {
+			p.CompletedCommands = types.Int64Value(int64(len(steps)))
+			p.checkpoints = checkpoints{"": steps}
+		}
+		hostPrior = &p
+	}
+
+	diags := mainRun(ctx, &hostData, hostPrior, config)
+	steps = hostData.checkpoints[""]
+	if hasErrors(diags) {
+		// The completed commands are kept in state as well, as only state reaches the create which replaces a
+		// resource whose create failed
+		completed := types.Int64Value(int64(len(steps)))
+		return hostResultModel{Stdout: types.StringNull(), Status: types.StringValue(hostStatusFailed), CompletedCommands: completed}, steps, diags
+	}
+	stdout := hostData.Result
+	if stdout.IsUnknown() {
+		// Commands did not run
+		stdout = types.StringNull()
+		if hostPrior != nil {
+			stdout = priorResult.Stdout
+		}
+	}
+	completed := types.Int64Value(int64(hostData.commandsLen()))
+	return hostResultModel{Stdout: stdout, Status: types.StringValue(hostStatusSuccess), CompletedCommands: completed}, steps, diags
+}
+
+// splitHostsEntry returns the host and port of an entry of 'hosts', which is either
+// a host, host:port or [host]:port. The port defaults to port
+func splitHostsEntry(entry string, port int64) (string, int64, error) {
+	host, entryPort, err := net.SplitHostPort(entry)
+	if err != nil {
+		return entry, port, nil
+	}
+	p, err := strconv.ParseInt(entryPort, 10, 64)
+	if err != nil || p < 1 || p > 65535 {
+		return "", 0, fmt.Errorf("invalid port in hosts entry %q", entry)
+	}
+	return host, p, nil
+}
@@ -0,0 +1,268 @@
+package ssh
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+)
+
+func TestHostBatches(t *testing.T) {
+	hosts := []string{"a", "b", "c", "d", "e"}
+	cases := map[string]struct {
+		strategy  string
+		batchSize int
+		want      [][]string
+	}{
+		"parallel": {
+			strategy: StrategyParallel,
+			want:     [][]string{{"a", "b", "c", "d", "e"}},
+		},
+		"default is parallel": {
+			want: [][]string{{"a", "b", "c", "d", "e"}},
+		},
+		"serial ignores batch size": {
+			strategy:  StrategySerial,
+			batchSize: 3,
+			want:      [][]string{{"a"}, {"b"}, {"c"}, {"d"}, {"e"}},
+		},
+		"rolling": {
+			strategy:  StrategyRolling,
+			batchSize: 2,
+			want:      [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+		},
+		"rolling defaults to batches of one": {
+			strategy: StrategyRolling,
+			want:     [][]string{{"a"}, {"b"}, {"c"}, {"d"}, {"e"}},
+		},
+		"rolling batch larger than hosts": {
+			strategy:  StrategyRolling,
+			batchSize: 10,
+			want:      [][]string{{"a", "b", "c", "d", "e"}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := hostBatches(hosts, c.strategy, c.batchSize); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestSplitHostsEntry(t *testing.T) {
+	cases := map[string]struct {
+		host string
+		port int64
+	}{
+		"example.com":         {"example.com", 22},
+		"example.com:2222":    {"example.com", 2222},
+		"10.0.0.1:2222":       {"10.0.0.1", 2222},
+		"[2001:db8::1]:2222":  {"2001:db8::1", 2222},
+		"2001:db8::1":         {"2001:db8::1", 22},
+		"[fe80::1%eth0]:2222": {"fe80::1%eth0", 2222},
+		"[2001:db8::1]":       {"[2001:db8::1]", 22},
+		"fe80::1%eth0":        {"fe80::1%eth0", 22},
+	}
+	for entry, want := range cases {
+		host, port, err := splitHostsEntry(entry, 22)
+		if err != nil || host != want.host || port != want.port {
+			t.Errorf("%s: expected %s %d, got %s %d %v", entry, want.host, want.port, host, port, err)
+		}
+	}
+	if _, _, err := splitHostsEntry("example.com:0", 22); err == nil {
+		t.Errorf("expected an error for port 0")
+	}
+}
+
+// testResourceModel returns a resource running commands on s, authenticating with its password
+func testResourceModel(t *testing.T, s *sshtest.Server, commands ...string) *sshResourceModel {
+	commandsValue, diags := types.ListValueFrom(context.Background(), types.StringType, commands)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	return &sshResourceModel{
+		Host:                           types.StringValue(s.Host),
+		Hosts:                          types.ListNull(types.StringType),
+		Strategy:                       types.StringNull(),
+		BatchSize:                      types.Int64Null(),
+		MaxFailPercentage:              types.Int64Null(),
+		Port:                           types.Int64Value(testPort(t, s)),
+		User:                           types.StringValue(s.User),
+		Password:                       types.StringValue(s.Password),
+		Agent:                          types.BoolValue(false),
+		PreCommands:                    types.ListNull(types.StringType),
+		Commands:                       commandsValue,
+		Command:                        types.ListValueMust(sshResourceSchema(false).Blocks["command"].Type().(types.ListType).ElemType, nil),
+		ExecutedCommands:               types.ListUnknown(types.StringType),
+		CommandsAfterFileChanges:       types.BoolValue(true),
+		OnFailure:                      types.StringNull(),
+		RollbackCommands:               types.ListNull(types.StringType),
+		CompletedCommands:              types.Int64Unknown(),
+		Timeout:                        types.StringValue(testTimeout.String()),
+		RetryDelay:                     types.StringValue(testRetryDelay.String()),
+		IgnoreNoSupportedMethodsRemain: types.BoolValue(false),
+		TransferProtocol:               types.StringValue(TransferProtocolSCP),
+		Result:                         types.StringUnknown(),
+		Results:                        types.MapUnknown(types.ObjectType{AttrTypes: hostResultAttrTypes}),
+		File:                           types.SetNull(sshResourceSchema(false).Blocks["file"].Type().(types.SetType).ElemType),
+	}
+}
+
+// testPort returns the port of s
+func testPort(t *testing.T, s *sshtest.Server) int64 {
+	port, err := strconv.ParseInt(s.Port, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+// testHostsModel returns a resource running testCommand on servers, which share their password
+func testHostsModel(t *testing.T, servers []*sshtest.Server, strategy string, batchSize, maxFailPercentage int64) *sshResourceModel {
+	var hosts []attr.Value
+	for _, s := range servers {
+		hosts = append(hosts, types.StringValue(s.Address()))
+	}
+	data := testResourceModel(t, servers[0], testCommand)
+	data.Host = types.StringNull()
+	data.Port = types.Int64Value(22)
+	data.Hosts = types.ListValueMust(types.StringType, hosts)
+	data.Strategy = types.StringValue(strategy)
+	data.BatchSize = types.Int64Value(batchSize)
+	data.MaxFailPercentage = types.Int64Value(maxFailPercentage)
+	return data
+}
+
+func TestRunHosts(t *testing.T) {
+	cases := map[string]struct {
+		strategy          string
+		batchSize         int64
+		maxFailPercentage int64
+		// Servers which reject authentication
+		failing     []int
+		wantStatus  []string
+		wantError   bool
+		wantWarning bool
+	}{
+		"parallel": {
+			strategy:   StrategyParallel,
+			wantStatus: []string{hostStatusSuccess, hostStatusSuccess, hostStatusSuccess},
+		},
+		"parallel failure": {
+			strategy:   StrategyParallel,
+			failing:    []int{0},
+			wantStatus: []string{hostStatusFailed, hostStatusSuccess, hostStatusSuccess},
+			wantError:  true,
+		},
+		"serial stops after failure": {
+			strategy:   StrategySerial,
+			failing:    []int{1},
+			wantStatus: []string{hostStatusSuccess, hostStatusFailed, hostStatusSkipped},
+			wantError:  true,
+		},
+		"rolling stops after failed batch": {
+			strategy:   StrategyRolling,
+			batchSize:  2,
+			failing:    []int{0},
+			wantStatus: []string{hostStatusFailed, hostStatusSuccess, hostStatusSkipped},
+			wantError:  true,
+		},
+		"failures within max_fail_percentage are warnings": {
+			strategy:          StrategySerial,
+			maxFailPercentage: 50,
+			failing:           []int{0},
+			wantStatus:        []string{hostStatusFailed, hostStatusSuccess, hostStatusSuccess},
+			wantWarning:       true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			servers := []*sshtest.Server{sshtest.NewServer(t), sshtest.NewServer(t), sshtest.NewServer(t)}
+			for _, i := range c.failing {
+				servers[i].SetFaults(sshtest.Faults{RejectAuth: sshtest.Always})
+			}
+			data := testHostsModel(t, servers, c.strategy, c.batchSize, c.maxFailPercentage)
+
+			diags := runHosts(context.Background(), data, nil, newConfig(os.DevNull))
+
+			if hasErrors(diags) != c.wantError {
+				t.Fatalf("expected error %t, got %v", c.wantError, diags)
+			}
+			if c.wantWarning && (len(diags) == 0 || !strings.HasPrefix(diags[0].Summary, servers[c.failing[0]].Address()+": ")) {
+				t.Errorf("expected a warning for the failed host, got %v", diags)
+			}
+			var results map[string]hostResultModel
+			if fwDiags := data.Results.ElementsAs(context.Background(), &results, false); fwDiags.HasError() {
+				t.Fatal(fwDiags)
+			}
+			for i, s := range servers {
+				result := results[s.Address()]
+				if status := result.Status.ValueString(); status != c.wantStatus[i] {
+					t.Errorf("host %d: expected status %s, got %s", i, c.wantStatus[i], status)
+				}
+				if c.wantStatus[i] == hostStatusSuccess && result.Stdout.ValueString() != "deployed\n" {
+					t.Errorf("host %d: expected stdout, got %q", i, result.Stdout.ValueString())
+				}
+				if ran := len(s.Commands()) > 0; ran != (c.wantStatus[i] == hostStatusSuccess) {
+					t.Errorf("host %d: expected command to run %t", i, !ran)
+				}
+			}
+		})
+	}
+}
+
+func TestRunHosts_update(t *testing.T) {
+	servers := []*sshtest.Server{sshtest.NewServer(t), sshtest.NewServer(t)}
+	prior := testHostsModel(t, servers, StrategyParallel, 0, 0)
+	prior.Results = types.MapValueMust(types.ObjectType{AttrTypes: hostResultAttrTypes}, map[string]attr.Value{
+		servers[0].Address(): types.ObjectValueMust(hostResultAttrTypes, map[string]attr.Value{
+			"stdout":             types.StringValue("before\n"),
+			"status":             types.StringValue(hostStatusSuccess),
+			"completed_commands": types.Int64Value(1),
+		}),
+		servers[1].Address(): types.ObjectValueMust(hostResultAttrTypes, map[string]attr.Value{
+			"stdout":             types.StringNull(),
+			"status":             types.StringValue(hostStatusFailed),
+			"completed_commands": types.Int64Value(0),
+		}),
+	})
+	data := testHostsModel(t, servers, StrategyParallel, 0, 0)
+	if !prior.hostsIncomplete() {
+		t.Errorf("expected a failed host to plan an update")
+	}
+
+	if diags := runHosts(context.Background(), data, prior, newConfig(os.DevNull)); hasErrors(diags) {
+		t.Fatal(diags)
+	}
+	if data.hostsIncomplete() {
+		t.Errorf("expected no update to be planned once all hosts succeeded")
+	}
+
+	// Unchanged hosts which succeeded are left alone, failed hosts are provisioned again
+	if n := len(servers[0].Commands()); n != 0 {
+		t.Errorf("expected provisioned host not to run commands, ran %d", n)
+	}
+	if n := len(servers[1].Commands()); n != 1 {
+		t.Errorf("expected failed host to run commands once, ran %d", n)
+	}
+	var results map[string]hostResultModel
+	if fwDiags := data.Results.ElementsAs(context.Background(), &results, false); fwDiags.HasError() {
+		t.Fatal(fwDiags)
+	}
+	for entry, want := range map[string]string{servers[0].Address(): "before\n", servers[1].Address(): "deployed\n"} {
+		if got := results[entry].Stdout.ValueString(); got != want {
+			t.Errorf("%s: expected stdout %q, got %q", entry, want, got)
+		}
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 results, got %d", len(results))
+	}
+}
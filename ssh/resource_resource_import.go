@@ -0,0 +1,164 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	fwdiag "github.com/hashicorp/terraform-plugin-framework/diag"
+	fwpath "github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// importedKey is the private state key marking a resource which was imported and not updated since.
+// Its first update adopts the configuration without provisioning, as the host already is
+const importedKey = "imported"
+
+// importTarget is a user, host and port parsed from an import ID
+type importTarget struct {
+	User string
+	Host string
+	Port int64
+}
+
+// ImportState adopts a provisioned host. The ID is user@host:port, optionally followed by the
+// bastion host as ,bastion_user@bastion_host:bastion_port. Users and ports may be left out, a resource
+// using 'hosts' is imported with one of them and adopts the configured hosts on its first update
+func (r *sshResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	host, bastion, err := parseImportID(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid import ID", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, fwpath.Root("id"), req.ID)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, fwpath.Root("host"), host.Host)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, fwpath.Root("port"), host.Port)...)
+	if host.User != "" {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, fwpath.Root("user"), host.User)...)
+	}
+	if bastion != nil {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, fwpath.Root("bastion_host"), bastion.Host)...)
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, fwpath.Root("bastion_port"), bastion.Port)...)
+		if bastion.User != "" {
+			resp.Diagnostics.Append(resp.State.SetAttribute(ctx, fwpath.Root("bastion_user"), bastion.User)...)
+		}
+	}
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, importedKey, []byte("true"))...)
+}
+
+// parseImportID parses an import ID in the user@host:port,bastion_user@bastion_host:bastion_port format.
+// IPv6 hosts are enclosed in brackets. The bastion is nil when the ID has none
+func parseImportID(id string) (importTarget, *importTarget, error) {
+	hostPart, bastionPart, hasBastion := strings.Cut(id, ",")
+	host, err := parseImportTarget(hostPart)
+	if err != nil {
+		return importTarget{}, nil, fmt.Errorf("invalid ID %q, expected user@host:port: %w", id, err)
+	}
+	if !hasBastion {
+		return host, nil, nil
+	}
+	bastion, err := parseImportTarget(bastionPart)
+	if err != nil {
+		return importTarget{}, nil, fmt.Errorf("invalid bastion in ID %q, expected bastion_user@bastion_host:bastion_port: %w", id, err)
+	}
+	return host, &bastion, nil
+}
+
+// parseImportTarget parses [user@]host[:port], the port defaults to 22
+func parseImportTarget(s string) (importTarget, error) {
+	target := importTarget{Host: s, Port: 22}
+	if user, rest, found := strings.Cut(s, "@"); found {
+		target.User, target.Host = user, rest
+		if user == "" {
+			return importTarget{}, fmt.Errorf("empty user")
+		}
+	}
+	if host, port, err := net.SplitHostPort(target.Host); err == nil {
+		p, err := strconv.ParseInt(port, 10, 64)
+		if err != nil || p < 1 || p > 65535 {
+			return importTarget{}, fmt.Errorf("invalid port %q", port)
+		}
+		target.Host, target.Port = host, p
+	}
+	if err := validateHost(target.Host); err != nil {
+		return importTarget{}, err
+	}
+	target.Host = normalizeHost(target.Host)
+	return target, nil
+}
+
+// readImported reports whether the resource was imported and not updated since
+func readImported(ctx context.Context, private privateState) (bool, fwdiag.Diagnostics) {
+	value, diags := private.GetKey(ctx, importedKey)
+	return len(value) > 0, diags
+}
+
+// resourceID returns the ID of data, its user, host and port followed by a hash of its file destinations and
+// commands. File content is left out as it may be sensitive. With 'hosts' the entries take the place of the host and port
+func resourceID(data *sshResourceModel) string {
+	destinations := make([]string, 0, len(data.File.Elements()))
+	for destination := range fileDestinations(data.File) {
+		destinations = append(destinations, destination)
+	}
+	sort.Strings(destinations)
+	content := strings.Join([]string{strings.Join(destinations, "\n"), data.PreCommands.String(), data.Commands.String(), data.Command.String()}, "\x00")
+	sum := sha256.Sum256([]byte(content))
+	return resourceTarget(data) + "#" + hex.EncodeToString(sum[:8])
+}
+
+// resourceTarget returns the user, host and port of data, or its entries of 'hosts', as in its ID
+func resourceTarget(data *sshResourceModel) string {
+	target := net.JoinHostPort(normalizeHost(data.Host.ValueString()), strconv.FormatInt(data.Port.ValueInt64(), 10))
+	if !data.Hosts.IsNull() {
+		var entries []string
+		for _, v := range data.Hosts.Elements() {
+			entries = append(entries, v.(types.String).ValueString())
+		}
+		target = strings.Join(entries, ",")
+	}
+	if user := data.User.ValueString(); user != "" {
+		target = user + "@" + target
+	}
+	return target
+}
+
+// planResourceID returns the ID resourceID derives from plan, or unknown when its inputs are not known yet
+func planResourceID(ctx context.Context, plan *sshResourceModel) types.String {
+	for _, v := range []attr.Value{plan.Host, plan.Hosts, plan.Port, plan.User, plan.File, plan.PreCommands, plan.Commands, plan.Command} {
+		if !fullyKnown(ctx, v) {
+			return types.StringUnknown()
+		}
+	}
+	return types.StringValue(resourceID(plan))
+}
+
+const requiresReplaceUnlessImportedDescription = "Changing the value replaces the resource, except on the first update after an import"
+
+// requiresReplaceUnlessImported replaces the resource when the value changes, except on the first update after
+// an import as the import does not set the value
+func requiresReplaceUnlessImported() planmodifier.String {
+	return stringplanmodifier.RequiresReplaceIf(func(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+		imported, diags := readImported(ctx, req.Private)
+		resp.Diagnostics.Append(diags...)
+		resp.RequiresReplace = !imported
+	}, requiresReplaceUnlessImportedDescription, requiresReplaceUnlessImportedDescription)
+}
+
+// mapRequiresReplaceUnlessImported is requiresReplaceUnlessImported for map attributes
+func mapRequiresReplaceUnlessImported() planmodifier.Map {
+	return mapplanmodifier.RequiresReplaceIf(func(ctx context.Context, req planmodifier.MapRequest, resp *mapplanmodifier.RequiresReplaceIfFuncResponse) {
+		imported, diags := readImported(ctx, req.Private)
+		resp.Diagnostics.Append(diags...)
+		resp.RequiresReplace = !imported
+	}, requiresReplaceUnlessImportedDescription, requiresReplaceUnlessImportedDescription)
+}
@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+)
+
+func TestParseImportID(t *testing.T) {
+	cases := map[string]struct {
+		host    importTarget
+		bastion *importTarget
+	}{
+		"ubuntu@10.0.0.1:22":      {host: importTarget{User: "ubuntu", Host: "10.0.0.1", Port: 22}},
+		"ubuntu@app.example.com":  {host: importTarget{User: "ubuntu", Host: "app.example.com", Port: 22}},
+		"app.example.com:2222":    {host: importTarget{Host: "app.example.com", Port: 2222}},
+		"root@[2001:db8::1]:2222": {host: importTarget{User: "root", Host: "2001:db8::1", Port: 2222}},
+		"root@[fe80::1%eth0]":     {host: importTarget{User: "root", Host: "fe80::1%eth0", Port: 22}},
+		"ubuntu@10.0.0.1:22,jump@bastion.example.com:2200": {
+			host:    importTarget{User: "ubuntu", Host: "10.0.0.1", Port: 22},
+			bastion: &importTarget{User: "jump", Host: "bastion.example.com", Port: 2200},
+		},
+	}
+	for id, want := range cases {
+		host, bastion, err := parseImportID(id)
+		if err != nil {
+			t.Errorf("%s: %v", id, err)
+			continue
+		}
+		if host != want.host || !reflect.DeepEqual(bastion, want.bastion) {
+			t.Errorf("%s: expected %v %v, got %v %v", id, want.host, want.bastion, host, bastion)
+		}
+	}
+
+	for _, id := range []string{"", "@10.0.0.1", "ubuntu@10.0.0.1:0", "ubuntu@10.0.0.1:ssh", "ubuntu@10.0.0.1,", "ubuntu@[10.0.0.1]"} {
+		if _, _, err := parseImportID(id); err == nil {
+			t.Errorf("%q: expected an error", id)
+		}
+	}
+}
+
+func TestResourceID(t *testing.T) {
+	s := sshtest.NewServer(t)
+	data := testResourceModel(t, s, "echo one")
+	id := resourceID(data)
+	if want := s.User + "@" + s.Address() + "#"; !strings.HasPrefix(id, want) {
+		t.Errorf("expected ID to start with %s, got %s", want, id)
+	}
+	if again := resourceID(testResourceModel(t, s, "echo one")); again != id {
+		t.Errorf("expected the same ID for the same configuration, got %s and %s", id, again)
+	}
+	if other := resourceID(testResourceModel(t, s, "echo two")); other == id {
+		t.Errorf("expected another ID for other commands, got %s", other)
+	}
+	data.File = testFiles(t, map[string]string{"/etc/app.conf": "secret"})
+	withFile := resourceID(data)
+	if withFile == id {
+		t.Errorf("expected another ID for other file destinations, got %s", withFile)
+	}
+	data.File = testFiles(t, map[string]string{"/etc/app.conf": "other secret"})
+	if got := resourceID(data); got != withFile {
+		t.Errorf("expected file content not to change the ID, got %s and %s", withFile, got)
+	}
+
+	data.Host = types.StringNull()
+	data.Hosts = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("10.0.0.1"), types.StringValue("[2001:db8::1]:2222")})
+	if got := resourceID(data); !strings.HasPrefix(got, s.User+"@10.0.0.1,[2001:db8::1]:2222#") {
+		t.Errorf("expected ID of the hosts, got %s", got)
+	}
+}
+
+func TestReadImported(t *testing.T) {
+	ctx := context.Background()
+	private := make(testPrivateState)
+	if imported, _ := readImported(ctx, private); imported {
+		t.Errorf("expected the resource not to be imported")
+	}
+	private[importedKey] = []byte("true")
+	if imported, _ := readImported(ctx, private); !imported {
+		t.Errorf("expected the resource to be imported")
+	}
+}
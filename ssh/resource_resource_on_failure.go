@@ -0,0 +1,113 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/loafoe/easyssh-proxy/v2"
+)
+
+const (
+	OnFailureFail     = "fail"
+	OnFailureContinue = "continue"
+	OnFailureRollback = "rollback"
+)
+
+// completedCommands returns the number of commands which completed in the last apply of data,
+// all of them when this was not recorded
+func (data *sshResourceModel) completedCommands() int {
+	if data.CompletedCommands.IsNull() || data.CompletedCommands.IsUnknown() {
+		return data.commandsLen()
+	}
+	return int(data.CompletedCommands.ValueInt64())
+}
+
+// provisioned reports whether provisioning started, as only then it records its progress
+func (data *sshResourceModel) provisioned() bool {
+	return !data.CompletedCommands.IsUnknown() || !data.Results.IsUnknown()
+}
+
+// runSteps runs the commands from start on for which run is set, and handles a failing command as set by
+// 'on_failure'. It returns the stdout of the last command which ran, the number of commands which completed
+// and the commands which ran. With 'fail' a failing command is retried until the timeout, with 'continue'
+// and 'rollback' a command which exits with a non-zero status fails immediately
+func runSteps(ctx context.Context, onFailure string, commands []string, run []bool, rollbackCommands []string, start int, ssh *easyssh.MakeConfig, sshRetryConfig SSHRetryConfig, config *Config) (string, int, []string, diag.Diagnostics) {
+	var stdout string
+	var diags diag.Diagnostics
+	// Commands before start completed in the apply which is resumed
+	var completed []int
+	for i := 0; i < start; i++ {
+		completed = append(completed, i)
+	}
+	var executed []string
+	for i := start; i < len(commands); i++ {
+		if !run[i] {
+			continue
+		}
+		executed = append(executed, commands[i])
+		if onFailure == OnFailureFail {
+			out, errDiags, err := runCommands(ctx, commands[i:i+1], ssh, sshRetryConfig, config)
+			if err != nil {
+				return stdout, i, executed, errDiags
+			}
+			stdout = out
+			completed = append(completed, i)
+			continue
+		}
+		result, err := runCommand(ctx, commands[i], ssh, sshRetryConfig, config)
+		if err == nil && result.ExitCode == 0 {
+			stdout = result.Stdout
+			completed = append(completed, i)
+			continue
+		}
+		failure := commandFailure(commands[i], result, err)
+		if onFailure == OnFailureContinue {
+			failure.Severity = diag.Warning
+			diags = append(diags, failure)
+			continue
+		}
+		diags = append(diags, failure)
+		return stdout, 0, executed, append(diags, rollback(rollbackCommands, completed, ssh, sshRetryConfig, config)...)
+	}
+	return stdout, len(commands), executed, diags
+}
+
+// rollback runs the rollback commands of the completed commands in reverse order. It has a timeout
+// of its own, as the failed command may have used up the timeout of the apply
+func rollback(rollbackCommands []string, completed []int, ssh *easyssh.MakeConfig, sshRetryConfig SSHRetryConfig, config *Config) diag.Diagnostics {
+	ctx, cancel := context.WithTimeout(context.Background(), sshRetryConfig.timeout)
+	defer cancel()
+
+	var diags diag.Diagnostics
+	for j := len(completed) - 1; j >= 0; j-- {
+		i := completed[j]
+		if i >= len(rollbackCommands) || rollbackCommands[i] == "" {
+			continue
+		}
+		command := rollbackCommands[i]
+		result, err := runCommand(ctx, command, ssh, sshRetryConfig, config)
+		if err != nil || result.ExitCode != 0 {
+			failure := commandFailure(command, result, err)
+			failure.Summary = "rollback: " + failure.Summary
+			diags = append(diags, failure)
+		}
+	}
+	return diags
+}
+
+// commandFailure describes a command which could not be run or exited with a non-zero status
+func commandFailure(command string, result commandResult, err error) diag.Diagnostic {
+	failure := diag.Diagnostic{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("command '%s' exited with status %d", command, result.ExitCode),
+		Detail:   result.Stdout,
+	}
+	if err != nil {
+		failure.Summary = fmt.Sprintf("execution of command '%s' failed: %s", command, err)
+	}
+	if result.Stderr != "" {
+		failure.Detail = result.Stderr
+	}
+	return failure
+}
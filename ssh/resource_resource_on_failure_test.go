@@ -0,0 +1,77 @@
+package ssh
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+)
+
+func TestMainRun_onFailure(t *testing.T) {
+	commands := []string{"echo one", "echo two", "exit 3", "echo four"}
+	cases := map[string]struct {
+		onFailure        string
+		rollbackCommands []string
+		wantCompleted    int64
+		wantSeverity     diag.Severity
+		wantResult       string
+		wantRolledBack   []string
+	}{
+		"fail": {
+			onFailure:     OnFailureFail,
+			wantCompleted: 2,
+			wantSeverity:  diag.Error,
+		},
+		"continue": {
+			onFailure:     OnFailureContinue,
+			wantCompleted: 4,
+			wantSeverity:  diag.Warning,
+			wantResult:    "four\n",
+		},
+		"rollback": {
+			onFailure:        OnFailureRollback,
+			rollbackCommands: []string{"echo undo-one >> rollback", "echo undo-two >> rollback", "echo undo-three >> rollback"},
+			wantCompleted:    0,
+			wantSeverity:     diag.Error,
+			wantRolledBack:   []string{"undo-two", "undo-one"},
+		},
+		"rollback skips empty commands": {
+			onFailure:        OnFailureRollback,
+			rollbackCommands: []string{"echo undo-one >> rollback", ""},
+			wantCompleted:    0,
+			wantSeverity:     diag.Error,
+			wantRolledBack:   []string{"undo-one"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := sshtest.NewServer(t)
+			data := testResourceModel(t, s, commands...)
+			data.OnFailure = types.StringValue(c.onFailure)
+			if c.rollbackCommands != nil {
+				data.RollbackCommands, _ = types.ListValueFrom(context.Background(), types.StringType, c.rollbackCommands)
+			}
+
+			diags := mainRun(context.Background(), data, nil, newConfig(os.DevNull))
+
+			if len(diags) == 0 || diags[0].Severity != c.wantSeverity || !strings.Contains(diags[0].Summary, "exit 3") {
+				t.Fatalf("expected the failed command to be reported, got %v", diags)
+			}
+			if got := data.CompletedCommands.ValueInt64(); got != c.wantCompleted {
+				t.Errorf("expected %d completed commands, got %d", c.wantCompleted, got)
+			}
+			if c.wantSeverity == diag.Warning && data.Result.ValueString() != c.wantResult {
+				t.Errorf("expected result %q, got %q", c.wantResult, data.Result.ValueString())
+			}
+			rolledBack, _ := os.ReadFile(s.Path("rollback"))
+			if got := strings.Fields(string(rolledBack)); strings.Join(got, " ") != strings.Join(c.wantRolledBack, " ") {
+				t.Errorf("expected rollback %v, got %v", c.wantRolledBack, got)
+			}
+		})
+	}
+}
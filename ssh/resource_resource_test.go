@@ -2,10 +2,15 @@ package ssh_test
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"path"
+	"regexp"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	"github.com/loafoe/terraform-provider-ssh/internal/acc"
 )
 
@@ -14,69 +19,207 @@ func TestAccResourceResource_basic(t *testing.T) {
 
 	resourceName := "ssh_resource.test"
 	randomName := acctest.RandStringFromCharSet(10, acctest.CharSetAlpha)
-	username := acc.AccUsername()
+	target := acc.NewTarget(t)
 
 	resource.Test(t, resource.TestCase{
 		PreCheck: func() {
 			acc.PreCheck(t)
 		},
-		ProviderFactories: acc.ProviderFactories,
+		ProtoV5ProviderFactories: acc.ProtoV5ProviderFactories,
+		CheckDestroy:             testAccCheckTargetFileRemoved(target, "terraform-provider-ssh-test-"+randomName),
 		Steps: []resource.TestStep{
 			{
 				ResourceName: resourceName,
-				Config:       testAccResourceResource(randomName),
+				Config:       testAccResourceResource(target, randomName),
 				Check: resource.ComposeTestCheckFunc(
-					resource.TestCheckResourceAttr(resourceName, "user", username)),
+					resource.TestCheckResourceAttr(resourceName, "user", target.User),
+					testAccCheckTargetFile(target, "terraform-provider-ssh-test-"+randomName+".txt", "hello "+randomName),
+					testAccCheckTargetFileExists(target, "terraform-provider-ssh-test-"+randomName)),
 			},
 		},
 	})
 }
 
-func testAccResourceResource(random string) string {
-	username := acc.AccUsername()
-	privateKey := acc.AccPrivateKey()
-	hostname := acc.AccHostname()
-
+func testAccResourceResource(target acc.Target, random string) string {
 	return fmt.Sprintf(`
 
 resource "ssh_resource" "test" {
-	host        = "%s"
-    user        = "%s"
-    agent       = false
-    private_key = "%s"
+	host        = "%[1]s"
+	port        = %[2]s
+	user        = "%[3]s"
+	agent       = false
+	private_key = <<-EOT
+%[4]s
+EOT
 
 	timeout = "5m"
 
 	retry_delay = "2s"
 
-    commands = [
-       "date > /tmp/terraform-provider-ssh-test-%s"
-    ]
+	file {
+		content     = "hello %[6]s"
+		destination = "%[5]s/terraform-provider-ssh-test-%[6]s.txt"
+	}
+
+	commands = [
+		"date > %[5]s/terraform-provider-ssh-test-%[6]s"
+	]
 }
 
 resource "ssh_resource" "destroy" {
-	host        = "%s"
-    user        = "%s"
-    agent       = false
-    private_key = "%s"
- 
-    when        = "destroy"
-
-    commands = [
-        "rm /tmp/terraform-provider-ssh-test-%s"
-    ]
+	host        = "%[1]s"
+	port        = %[2]s
+	user        = "%[3]s"
+	agent       = false
+	private_key = <<-EOT
+%[4]s
+EOT
+
+	when        = "destroy"
+
+	commands = [
+		"rm %[5]s/terraform-provider-ssh-test-%[6]s %[5]s/terraform-provider-ssh-test-%[6]s.txt"
+	]
+}
+`, target.Host, target.Port, target.User, target.PrivateKey, target.Dir, random)
+}
+
+func TestAccResourceResource_import(t *testing.T) {
+	t.Parallel()
+
+	resourceName := "ssh_resource.test"
+	cases := map[string]struct {
+		hosts    bool
+		importID func(target acc.Target) string
+	}{
+		"user@host:port": {
+			importID: func(target acc.Target) string {
+				return fmt.Sprintf("%s@%s", target.User, net.JoinHostPort(target.Host, target.Port))
+			},
+		},
+		"host:port": {
+			// The user is adopted from the configuration
+			importID: func(target acc.Target) string {
+				return net.JoinHostPort(target.Host, target.Port)
+			},
+		},
+		"hosts": {
+			// The configured hosts take the place of the imported host
+			hosts: true,
+			importID: func(target acc.Target) string {
+				return fmt.Sprintf("%s@%s", target.User, net.JoinHostPort(target.Host, target.Port))
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			randomName := acctest.RandStringFromCharSet(10, acctest.CharSetAlpha)
+			target := acc.NewTarget(t)
+			config := testAccResourceResourceImport(target, randomName, c.hosts)
+
+			resource.Test(t, resource.TestCase{
+				PreCheck: func() {
+					acc.PreCheck(t)
+				},
+				ProtoV5ProviderFactories: acc.ProtoV5ProviderFactories,
+				Steps: []resource.TestStep{
+					{
+						ResourceName:       resourceName,
+						Config:             config,
+						ImportState:        true,
+						ImportStateId:      c.importID(target),
+						ImportStatePersist: true,
+					},
+					{
+						// The imported host is adopted, its commands do not run
+						Config: config,
+						Check: resource.ComposeTestCheckFunc(
+							resource.TestMatchResourceAttr(resourceName, "id", regexp.MustCompile(`^`+regexp.QuoteMeta(target.User)+`@.+#[0-9a-f]{16}$`)),
+							resource.TestCheckResourceAttr(resourceName, "user", target.User),
+							resource.TestCheckResourceAttr(resourceName, "executed_commands.#", "0"),
+							testAccCheckTargetFileMissing(target, "terraform-provider-ssh-test-"+randomName)),
+					},
+				},
+			})
+		})
+	}
+}
+
+func testAccResourceResourceImport(target acc.Target, random string, hosts bool) string {
+	destination := fmt.Sprintf(`host        = "%s"
+	port        = %s`, target.Host, target.Port)
+	if hosts {
+		destination = fmt.Sprintf(`hosts       = ["%s"]`, net.JoinHostPort(target.Host, target.Port))
+	}
+	return fmt.Sprintf(`
+
+resource "ssh_resource" "test" {
+	%[1]s
+	user        = "%[2]s"
+	agent       = false
+	private_key = <<-EOT
+%[3]s
+EOT
+
+	commands = [
+		"date > %[4]s/terraform-provider-ssh-test-%[5]s"
+	]
 }
-`,
-		// SSH Resource test
-		hostname,
-		username,
-		privateKey,
-		random,
-
-		// SSH Resource destroy
-		hostname,
-		username,
-		privateKey,
-		random,
-	)
+`, destination, target.User, target.PrivateKey, target.Dir, random)
+}
+
+// testAccCheckTargetFile checks the content of a file on the in-process server.
+// Files on a remote host can not be inspected so the check passes there
+func testAccCheckTargetFile(target acc.Target, name, content string) resource.TestCheckFunc {
+	return func(_ *terraform.State) error {
+		if target.Server == nil {
+			return nil
+		}
+		data, err := os.ReadFile(target.Server.Path(name))
+		if err != nil {
+			return err
+		}
+		if string(data) != content {
+			return fmt.Errorf("%s: expected content %q, got %q", name, content, data)
+		}
+		return nil
+	}
+}
+
+func testAccCheckTargetFileExists(target acc.Target, name string) resource.TestCheckFunc {
+	return func(_ *terraform.State) error {
+		if target.Server == nil {
+			return nil
+		}
+		_, err := os.Stat(target.Server.Path(name))
+		return err
+	}
+}
+
+// testAccCheckTargetFileMissing checks a file was not created on the in-process server
+func testAccCheckTargetFileMissing(target acc.Target, name string) resource.TestCheckFunc {
+	return func(_ *terraform.State) error {
+		if target.Server == nil {
+			return nil
+		}
+		if _, err := os.Stat(target.Server.Path(name)); !os.IsNotExist(err) {
+			return fmt.Errorf("%s: expected file not to be created", name)
+		}
+		return nil
+	}
+}
+
+// testAccCheckTargetFileRemoved checks the destroy commands removed a file from the in-process server
+func testAccCheckTargetFileRemoved(target acc.Target, name string) resource.TestCheckFunc {
+	return func(_ *terraform.State) error {
+		if target.Server == nil {
+			return nil
+		}
+		if _, err := os.Stat(target.Server.Path(name)); !os.IsNotExist(err) {
+			return fmt.Errorf("%s: expected file to be removed on destroy", path.Base(name))
+		}
+		return nil
+	}
 }
@@ -0,0 +1,295 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	fwschema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/defaults"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// legacyProvider serves the SDK implementations of ssh_resource and ssh_sensitive_resource.
+// It is never configured, only their state upgraders are used
+func legacyProvider() *schema.Provider {
+	return &schema.Provider{
+		ResourcesMap: map[string]*schema.Resource{
+			"ssh_resource":           resourceResourceV5(),
+			"ssh_sensitive_resource": sensitiveResourceResourceV1(),
+		},
+	}
+}
+
+// legacyStateUpgrader returns a state upgrader which migrates state of the given schema version
+// with the SDK implementation of typeName and converts the result to the current schema.
+// This keeps the behaviour of the SDK upgraders, including their handling of flatmap state.
+// Attributes the state predates are set to their default, the SDK upgraders leave them null
+func legacyStateUpgrader(typeName string, version int64) resource.StateUpgrader {
+	return resource.StateUpgrader{
+		StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+			server := schema.NewGRPCProviderServer(legacyProvider())
+
+			schemaResp, err := server.GetProviderSchema(ctx, &tfprotov5.GetProviderSchemaRequest{})
+			if err != nil {
+				resp.Diagnostics.AddError("Unable to Upgrade Resource State", err.Error())
+				return
+			}
+			var rawState *tfprotov5.RawState
+			if req.RawState != nil {
+				rawState = &tfprotov5.RawState{
+					JSON:    req.RawState.JSON,
+					Flatmap: req.RawState.Flatmap,
+				}
+			}
+			upgradeResp, err := server.UpgradeResourceState(ctx, &tfprotov5.UpgradeResourceStateRequest{
+				TypeName: typeName,
+				Version:  version,
+				RawState: rawState,
+			})
+			if err != nil {
+				resp.Diagnostics.AddError("Unable to Upgrade Resource State", err.Error())
+				return
+			}
+			for _, d := range upgradeResp.Diagnostics {
+				if d.Severity == tfprotov5.DiagnosticSeverityError {
+					resp.Diagnostics.AddError(d.Summary, d.Detail)
+				} else {
+					resp.Diagnostics.AddWarning(d.Summary, d.Detail)
+				}
+			}
+			if resp.Diagnostics.HasError() {
+				return
+			}
+
+			legacyValue, err := upgradeResp.UpgradedState.Unmarshal(schemaResp.ResourceSchemas[typeName].ValueType())
+			if err != nil {
+				resp.Diagnostics.AddError("Unable to Upgrade Resource State", err.Error())
+				return
+			}
+			setUpgradedState(ctx, legacyValue, resp)
+		},
+	}
+}
+
+// stringPortsStateUpgrader returns a state upgrader for state of the framework implementation
+// written before 'port' and 'bastion_port' were numbers
+func stringPortsStateUpgrader() resource.StateUpgrader {
+	return resource.StateUpgrader{
+		StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+			if req.RawState == nil {
+				resp.Diagnostics.AddError("Unable to Upgrade Resource State", "missing state")
+				return
+			}
+			currentType := resp.State.Schema.Type().TerraformType(ctx).(tftypes.Object)
+			priorType := tftypes.Object{AttributeTypes: make(map[string]tftypes.Type, len(currentType.AttributeTypes))}
+			for k, t := range currentType.AttributeTypes {
+				priorType.AttributeTypes[k] = t
+			}
+			priorType.AttributeTypes["port"] = tftypes.String
+			priorType.AttributeTypes["bastion_port"] = tftypes.String
+
+			priorValue, err := req.RawState.Unmarshal(priorType)
+			if err != nil {
+				resp.Diagnostics.AddError("Unable to Upgrade Resource State", err.Error())
+				return
+			}
+			setUpgradedState(ctx, priorValue, resp)
+		},
+	}
+}
+
+// setUpgradedState converts v to the current schema and sets it as the upgraded state
+func setUpgradedState(ctx context.Context, v tftypes.Value, resp *resource.UpgradeStateResponse) {
+	currentType := resp.State.Schema.Type().TerraformType(ctx)
+	value, err := conformValue(v, currentType)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to Upgrade Resource State", err.Error())
+		return
+	}
+	if current, ok := resp.State.Schema.(fwschema.Schema); ok {
+		value, err = withDefaults(ctx, current.Attributes, current.Blocks, value)
+		if err != nil {
+			resp.Diagnostics.AddError("Unable to Upgrade Resource State", err.Error())
+			return
+		}
+	}
+	dynamicValue, err := tfprotov6.NewDynamicValue(currentType, value)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to Upgrade Resource State", err.Error())
+		return
+	}
+	resp.DynamicValue = &dynamicValue
+}
+
+// conformValue converts v to typ. Object attributes missing from v are set to null and
+// attributes which typ does not have are dropped. Strings are converted to numbers, as ports
+// were strings before schema version 6. All other values must be of the same type
+func conformValue(v tftypes.Value, typ tftypes.Type) (tftypes.Value, error) {
+	if !v.IsKnown() {
+		return tftypes.NewValue(typ, tftypes.UnknownValue), nil
+	}
+	if v.IsNull() {
+		return tftypes.NewValue(typ, nil), nil
+	}
+	switch t := typ.(type) {
+	case tftypes.Object:
+		var attrs map[string]tftypes.Value
+		if err := v.As(&attrs); err != nil {
+			return tftypes.Value{}, err
+		}
+		values := make(map[string]tftypes.Value, len(t.AttributeTypes))
+		for k, attrType := range t.AttributeTypes {
+			attr, ok := attrs[k]
+			if !ok {
+				values[k] = tftypes.NewValue(attrType, nil)
+				continue
+			}
+			value, err := conformValue(attr, attrType)
+			if err != nil {
+				return tftypes.Value{}, fmt.Errorf("%s: %w", k, err)
+			}
+			values[k] = value
+		}
+		return tftypes.NewValue(t, values), nil
+	case tftypes.Set:
+		elems, err := conformElements(v, t.ElementType)
+		if err != nil {
+			return tftypes.Value{}, err
+		}
+		return tftypes.NewValue(t, elems), nil
+	case tftypes.List:
+		elems, err := conformElements(v, t.ElementType)
+		if err != nil {
+			return tftypes.Value{}, err
+		}
+		return tftypes.NewValue(t, elems), nil
+	case tftypes.Map:
+		var elems map[string]tftypes.Value
+		if err := v.As(&elems); err != nil {
+			return tftypes.Value{}, err
+		}
+		values := make(map[string]tftypes.Value, len(elems))
+		for k, elem := range elems {
+			value, err := conformValue(elem, t.ElementType)
+			if err != nil {
+				return tftypes.Value{}, fmt.Errorf("%s: %w", k, err)
+			}
+			values[k] = value
+		}
+		return tftypes.NewValue(t, values), nil
+	default:
+		if v.Type().Is(tftypes.String) && typ.Is(tftypes.Number) {
+			return stringToNumber(v)
+		}
+		if !v.Type().Equal(typ) {
+			return tftypes.Value{}, fmt.Errorf("cannot convert %s to %s", v.Type(), typ)
+		}
+		return v, nil
+	}
+}
+
+// stringToNumber converts a string value to a number, an empty string to null
+func stringToNumber(v tftypes.Value) (tftypes.Value, error) {
+	var s string
+	if err := v.As(&s); err != nil {
+		return tftypes.Value{}, err
+	}
+	if s == "" {
+		return tftypes.NewValue(tftypes.Number, nil), nil
+	}
+	n, ok := new(big.Float).SetString(s)
+	if !ok {
+		return tftypes.Value{}, fmt.Errorf("cannot convert %q to a number", s)
+	}
+	return tftypes.NewValue(tftypes.Number, n), nil
+}
+
+func conformElements(v tftypes.Value, elemType tftypes.Type) ([]tftypes.Value, error) {
+	var elems []tftypes.Value
+	if err := v.As(&elems); err != nil {
+		return nil, err
+	}
+	values := make([]tftypes.Value, 0, len(elems))
+	for _, elem := range elems {
+		value, err := conformValue(elem, elemType)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// withDefaults sets the null attributes of object v which have a default to that default,
+// including those of the objects in nested set blocks, and null list blocks to empty lists
+func withDefaults(ctx context.Context, attributes map[string]fwschema.Attribute, blocks map[string]fwschema.Block, v tftypes.Value) (tftypes.Value, error) {
+	if v.IsNull() || !v.IsKnown() {
+		return v, nil
+	}
+	var values map[string]tftypes.Value
+	if err := v.As(&values); err != nil {
+		return tftypes.Value{}, err
+	}
+	for k, attr := range attributes {
+		if !values[k].IsNull() {
+			continue
+		}
+		if value, ok := defaultValue(ctx, attr); ok {
+			values[k] = value
+		}
+	}
+	for k, block := range blocks {
+		// List blocks added since the state was written are empty
+		if _, ok := block.(fwschema.ListNestedBlock); ok && values[k].IsNull() {
+			values[k] = tftypes.NewValue(values[k].Type(), []tftypes.Value{})
+			continue
+		}
+		setBlock, ok := block.(fwschema.SetNestedBlock)
+		if !ok || values[k].IsNull() || !values[k].IsKnown() {
+			continue
+		}
+		var elems []tftypes.Value
+		if err := values[k].As(&elems); err != nil {
+			return tftypes.Value{}, err
+		}
+		for i, elem := range elems {
+			value, err := withDefaults(ctx, setBlock.NestedObject.Attributes, setBlock.NestedObject.Blocks, elem)
+			if err != nil {
+				return tftypes.Value{}, fmt.Errorf("%s: %w", k, err)
+			}
+			elems[i] = value
+		}
+		values[k] = tftypes.NewValue(values[k].Type(), elems)
+	}
+	return tftypes.NewValue(v.Type(), values), nil
+}
+
+// defaultValue returns the static default of attr, if it has one
+func defaultValue(ctx context.Context, attr fwschema.Attribute) (tftypes.Value, bool) {
+	switch a := attr.(type) {
+	case fwschema.StringAttribute:
+		if a.Default != nil {
+			var resp defaults.StringResponse
+			a.Default.DefaultString(ctx, defaults.StringRequest{}, &resp)
+			return tftypes.NewValue(tftypes.String, resp.PlanValue.ValueString()), true
+		}
+	case fwschema.BoolAttribute:
+		if a.Default != nil {
+			var resp defaults.BoolResponse
+			a.Default.DefaultBool(ctx, defaults.BoolRequest{}, &resp)
+			return tftypes.NewValue(tftypes.Bool, resp.PlanValue.ValueBool()), true
+		}
+	case fwschema.Int64Attribute:
+		if a.Default != nil {
+			var resp defaults.Int64Response
+			a.Default.DefaultInt64(ctx, defaults.Int64Request{}, &resp)
+			return tftypes.NewValue(tftypes.Number, resp.PlanValue.ValueInt64()), true
+		}
+	}
+	return tftypes.Value{}, false
+}
@@ -0,0 +1,225 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	fwschema "github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestSSHResourceUpgradeState_golden upgrades the state fixtures in testdata/upgrade, named
+// <type>_v<version>.json, through the provider server and compares the result with the
+// matching .golden file. Run with -update to regenerate the golden files
+func TestSSHResourceUpgradeState_golden(t *testing.T) {
+	ctx := context.Background()
+	server := providerserver.NewProtocol5(NewFrameworkProvider())()
+	schemaResp, err := server.GetProviderSchema(ctx, &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		typeName string
+		version  int64
+	}{
+		{"ssh_resource", 0},
+		{"ssh_resource", 1},
+		{"ssh_resource", 2},
+		{"ssh_resource", 3},
+		{"ssh_resource", 4},
+		{"ssh_resource", 5},
+		{"ssh_sensitive_resource", 1},
+	}
+	for _, c := range cases {
+		name := fmt.Sprintf("%s_v%d", c.typeName, c.version)
+		t.Run(name, func(t *testing.T) {
+			fixture := filepath.Join("testdata", "upgrade", name+".json")
+			raw, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			resp, err := server.UpgradeResourceState(ctx, &tfprotov5.UpgradeResourceStateRequest{
+				TypeName: c.typeName,
+				Version:  c.version,
+				RawState: &tfprotov5.RawState{JSON: raw},
+			})
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			for _, d := range resp.Diagnostics {
+				t.Fatalf("%s: %s", d.Summary, d.Detail)
+			}
+			value, err := resp.UpgradedState.Unmarshal(schemaResp.ResourceSchemas[c.typeName].ValueType())
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			current := sshResourceSchema(c.typeName == "ssh_sensitive_resource")
+			assertDefaultsFilled(t, "", current.Attributes, current.Blocks, value)
+
+			got, err := json.MarshalIndent(stateJSON(value), "", "  ")
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			got = append(got, '\n')
+			golden := filepath.Join("testdata", "upgrade", name+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0600); err != nil {
+					t.Fatalf("err: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("upgraded state of %s does not match %s:\n%s", fixture, golden, got)
+			}
+		})
+	}
+}
+
+// assertDefaultsFilled checks the attributes of object v which have a default and its blocks are set
+func assertDefaultsFilled(t *testing.T, prefix string, attributes map[string]fwschema.Attribute, blocks map[string]fwschema.Block, v tftypes.Value) {
+	t.Helper()
+	var values map[string]tftypes.Value
+	if err := v.As(&values); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for k, attr := range attributes {
+		if _, ok := defaultValue(context.Background(), attr); ok && values[k].IsNull() {
+			t.Errorf("attribute %s%s with a default is null", prefix, k)
+		}
+	}
+	for k, block := range blocks {
+		if values[k].IsNull() {
+			t.Errorf("block %s%s is null", prefix, k)
+		}
+		var elems []tftypes.Value
+		if err := values[k].As(&elems); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		var nested fwschema.NestedBlockObject
+		switch b := block.(type) {
+		case fwschema.SetNestedBlock:
+			nested = b.NestedObject
+		case fwschema.ListNestedBlock:
+			nested = b.NestedObject
+		}
+		for _, elem := range elems {
+			assertDefaultsFilled(t, k+".", nested.Attributes, nested.Blocks, elem)
+		}
+	}
+}
+
+// stateJSON converts v to a value which encodes to the JSON representation of state
+func stateJSON(v tftypes.Value) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+	switch {
+	case v.Type().Is(tftypes.String):
+		var s string
+		_ = v.As(&s)
+		return s
+	case v.Type().Is(tftypes.Bool):
+		var b bool
+		_ = v.As(&b)
+		return b
+	case v.Type().Is(tftypes.Number):
+		var n big.Float
+		_ = v.As(&n)
+		return json.Number(n.Text('f', -1))
+	case v.Type().Is(tftypes.List{}), v.Type().Is(tftypes.Set{}):
+		var elems []tftypes.Value
+		_ = v.As(&elems)
+		values := make([]interface{}, 0, len(elems))
+		for _, elem := range elems {
+			values = append(values, stateJSON(elem))
+		}
+		return values
+	default:
+		var attrs map[string]tftypes.Value
+		_ = v.As(&attrs)
+		values := make(map[string]interface{}, len(attrs))
+		for k, attr := range attrs {
+			values[k] = stateJSON(attr)
+		}
+		return values
+	}
+}
+
+func TestSSHResourceSchema_stateCompatible(t *testing.T) {
+	ctx := context.Background()
+	schemaResp, err := schema.NewGRPCProviderServer(legacyProvider()).GetProviderSchema(ctx, &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for typeName, sensitive := range map[string]bool{"ssh_resource": false, "ssh_sensitive_resource": true} {
+		legacy := schemaResp.ResourceSchemas[typeName]
+		current := sshResourceSchema(sensitive)
+		// Version 6 (2 for ssh_sensitive_resource) made the ports numbers
+		if current.Version != legacy.Version+1 {
+			t.Errorf("%s: schema version %d, expected %d", typeName, current.Version, legacy.Version+1)
+		}
+		// Attributes may be added, but those of the SDK implementation must keep their type
+		currentAttrs := current.Type().TerraformType(ctx).(tftypes.Object).AttributeTypes
+		for k, legacyType := range legacy.ValueType().(tftypes.Object).AttributeTypes {
+			if k == "port" || k == "bastion_port" {
+				legacyType = tftypes.Number
+			}
+			if currentType, ok := currentAttrs[k]; !ok || !currentType.Equal(legacyType) {
+				t.Errorf("%s: attribute %s of type %s is not kept, got %v", typeName, k, legacyType, currentType)
+			}
+		}
+	}
+}
+
+func TestLegacyStateUpgrader(t *testing.T) {
+	ctx := context.Background()
+	current := sshResourceSchema(false)
+	resp := resource.UpgradeStateResponse{State: tfsdk.State{Schema: current}}
+	upgrader := (&sshResource{}).UpgradeState(ctx)[4]
+	upgrader.StateUpgrader(ctx, resource.UpgradeStateRequest{
+		RawState: &tfprotov6.RawState{JSON: []byte(`{"id":"42","host":"example.com","user":"root","commands":["true"],"result":"ok","file":[{"destination":"/tmp/a","content":"a"}]}`)},
+	}, &resp)
+	for _, d := range resp.Diagnostics {
+		t.Fatalf("%s: %s", d.Summary(), d.Detail())
+	}
+	value, err := resp.DynamicValue.Unmarshal(current.Type().TerraformType(ctx))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var attrs map[string]tftypes.Value
+	if err := value.As(&attrs); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var ignore bool
+	if err := attrs["ignore_no_supported_methods_remain"].As(&ignore); err != nil || ignore {
+		t.Errorf("expected ignore_no_supported_methods_remain to be false")
+	}
+	var id string
+	if err := attrs["id"].As(&id); err != nil || id != "42" {
+		t.Errorf("unexpected id %q", id)
+	}
+	var files []tftypes.Value
+	if err := attrs["file"].As(&files); err != nil || len(files) != 1 {
+		t.Errorf("expected one file, got %v", attrs["file"])
+	}
+}
@@ -0,0 +1,179 @@
+package ssh
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceResourceV5 is the last SDK implementation of ssh_resource. It is only used to
+// migrate state of schema versions 0 to 4, which produces version 5 state of this schema
+func resourceResourceV5() *schema.Resource {
+	return &schema.Resource{
+		SchemaVersion: 5,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Type:    resourceResourceV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: patchResourceV0,
+				Version: 0,
+			},
+			{
+				Type:    resourceResourceV1().CoreConfigSchema().ImpliedType(),
+				Upgrade: patchResourceV1,
+				Version: 1,
+			},
+			{
+				Type:    resourceResourceV2().CoreConfigSchema().ImpliedType(),
+				Upgrade: patchResourceV2,
+				Version: 2,
+			},
+			{
+				Type:    resourceResourceV3().CoreConfigSchema().ImpliedType(),
+				Upgrade: patchResourceV3,
+				Version: 3,
+			},
+			{
+				Type:    resourceResourceV4().CoreConfigSchema().ImpliedType(),
+				Upgrade: patchResourceV4,
+				Version: 4,
+			},
+		},
+		Schema: resourceResourceV5Schema(false),
+	}
+}
+
+// sensitiveResourceResourceV1 is the last SDK implementation of ssh_sensitive_resource
+func sensitiveResourceResourceV1() *schema.Resource {
+	return &schema.Resource{
+		SchemaVersion: 1,
+		Schema:        resourceResourceV5Schema(true),
+	}
+}
+
+func resourceResourceV5Schema(sensitive bool) map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"when": {
+			Description:  "Determines when the commands is to be executed. Options are 'create' or 'destroy'",
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      "create",
+			ValidateFunc: validation.StringInSlice([]string{"create", "destroy"}, false),
+		},
+		"triggers": {
+			Description: "A map of arbitrary strings that, when changed, will force the 'hsdp_container_host_exec' resource to be replaced, re-running any associated commands.",
+			Type:        schema.TypeMap,
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"host": {
+			Type:     schema.TypeString,
+			Required: true,
+			ForceNew: true,
+		},
+		"port": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  "22",
+		},
+		"bastion_host": {
+			Type:     schema.TypeString,
+			Optional: true,
+		},
+		"bastion_port": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  "22",
+		},
+		"user": {
+			Type:     schema.TypeString,
+			Optional: true,
+			ForceNew: true,
+		},
+		"host_user": {
+			Type:       schema.TypeString,
+			Optional:   true,
+			ForceNew:   true,
+			Deprecated: "Use 'user' and 'bastion_user'",
+		},
+		"bastion_user": {
+			Type:     schema.TypeString,
+			Optional: true,
+			ForceNew: true,
+		},
+		"password": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"bastion_password": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"private_key": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"host_private_key": {
+			Type:       schema.TypeString,
+			Optional:   true,
+			Sensitive:  true,
+			Deprecated: "Use 'private_key' and 'bastion_private_key'",
+		},
+		"bastion_private_key": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"agent": {
+			Type:     schema.TypeBool,
+			Optional: true,
+			Default:  false,
+		},
+		"pre_commands": {
+			Type:     schema.TypeList,
+			MaxItems: 100,
+			Optional: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"commands": {
+			Type:     schema.TypeList,
+			MaxItems: 100,
+			Optional: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"commands_after_file_changes": {
+			Type:     schema.TypeBool,
+			Optional: true,
+			Default:  true,
+		},
+		"timeout": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  "5m",
+		},
+		"ignore_no_supported_methods_remain": {
+			Type:     schema.TypeBool,
+			Optional: true,
+			Default:  false,
+		},
+		"retry_delay": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Default:  "10s",
+		},
+		"transfer_protocol": transferProtocolSchema(),
+		"result": {
+			Type:      schema.TypeString,
+			Computed:  true,
+			Sensitive: sensitive,
+		},
+		"file": {
+			Type:     schema.TypeSet,
+			Optional: true,
+			Elem: &schema.Resource{
+				Schema: fileSchema(sensitive),
+			},
+		},
+	}
+}
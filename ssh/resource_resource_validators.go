@@ -0,0 +1,144 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// durationValidator validates that a string is a positive duration such as '30s' or '5m'
+type durationValidator struct{}
+
+func (v durationValidator) Description(_ context.Context) string {
+	return "value must be a positive duration such as '30s' or '5m'"
+}
+
+func (v durationValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v durationValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	d, err := time.ParseDuration(req.ConfigValue.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid duration", err.Error())
+		return
+	}
+	if d <= 0 {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid duration", fmt.Sprintf("duration %s must be positive", d))
+	}
+}
+
+// hostValidator validates that a string is a hostname or an IP address. IPv6 addresses may be
+// enclosed in brackets and include a zone, such as '[fe80::1%eth0]'. With port set the host
+// may be followed by a port, as host:port or [host]:port
+type hostValidator struct {
+	port bool
+}
+
+func (v hostValidator) Description(_ context.Context) string {
+	if v.port {
+		return "value must be a hostname or IP address, optionally followed by a port as host:port or [host]:port"
+	}
+	return "value must be a hostname or IP address"
+}
+
+func (v hostValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v hostValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	host := req.ConfigValue.ValueString()
+	if v.port {
+		if h, port, err := net.SplitHostPort(host); err == nil {
+			if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+				resp.Diagnostics.AddAttributeError(req.Path, "Invalid port",
+					fmt.Sprintf("port of %s must be between 1 and 65535", host))
+				return
+			}
+			host = h
+		}
+	}
+	if err := validateHost(host); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid host", fmt.Sprintf("%s: %s", v.Description(ctx), err))
+	}
+}
+
+// validateHost checks host is a hostname or an IP address, IPv6 addresses may be enclosed in brackets
+func validateHost(host string) error {
+	bracketed := normalizeHost(host) != host
+	host = normalizeHost(host)
+	switch {
+	case host == "":
+		return errors.New("host is empty")
+	case strings.ContainsAny(host, "[] \t\n/"):
+		return fmt.Errorf("invalid host %q", host)
+	case !strings.Contains(host, ":"):
+		if bracketed || strings.Contains(host, "%") {
+			return fmt.Errorf("only IPv6 addresses may be enclosed in brackets or have a zone, got %q", host)
+		}
+		return nil
+	}
+	address, zone, hasZone := strings.Cut(host, "%")
+	if ip := net.ParseIP(address); ip == nil || (hasZone && zone == "") {
+		return fmt.Errorf("invalid IPv6 address %q", host)
+	}
+	return nil
+}
+
+// fileModeValidator validates that a string is an octal file mode such as '0644'
+type fileModeValidator struct{}
+
+func (v fileModeValidator) Description(_ context.Context) string {
+	return "value must be an octal file mode such as '0644'"
+}
+
+func (v fileModeValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v fileModeValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() || req.ConfigValue.ValueString() == "" {
+		return
+	}
+	mode := req.ConfigValue.ValueString()
+	if _, err := strconv.ParseUint(mode, 8, 12); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid file mode",
+			fmt.Sprintf("%s, got: %s", v.Description(ctx), mode))
+	}
+}
+
+// privateKeyValidator validates that a string is a PEM encoded private key. The key is not
+// included in diagnostics, encrypted keys are accepted as their passphrase is set separately
+type privateKeyValidator struct{}
+
+func (v privateKeyValidator) Description(_ context.Context) string {
+	return "value must be a PEM encoded private key"
+}
+
+func (v privateKeyValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v privateKeyValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() || req.ConfigValue.ValueString() == "" {
+		return
+	}
+	_, err := gossh.ParseRawPrivateKey([]byte(req.ConfigValue.ValueString()))
+	var passphraseErr *gossh.PassphraseMissingError
+	if err != nil && !errors.As(err, &passphraseErr) {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid private key", err.Error())
+	}
+}
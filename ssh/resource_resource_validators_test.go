@@ -0,0 +1,137 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	fwpath "github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestStringValidators(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := gossh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := gossh.MarshalPrivateKeyWithPassphrase(key, "", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		validator validator.String
+		value     types.String
+		wantError bool
+	}{
+		"duration":              {validator: durationValidator{}, value: types.StringValue("5m")},
+		"duration invalid":      {validator: durationValidator{}, value: types.StringValue("5 minutes"), wantError: true},
+		"duration zero":         {validator: durationValidator{}, value: types.StringValue("0s"), wantError: true},
+		"duration unknown":      {validator: durationValidator{}, value: types.StringUnknown()},
+		"host":                  {validator: hostValidator{}, value: types.StringValue("example.com")},
+		"host IPv4":             {validator: hostValidator{}, value: types.StringValue("10.0.0.1")},
+		"host IPv6":             {validator: hostValidator{}, value: types.StringValue("2001:db8::1")},
+		"host IPv6 brackets":    {validator: hostValidator{}, value: types.StringValue("[2001:db8::1]")},
+		"host IPv6 zone":        {validator: hostValidator{}, value: types.StringValue("[fe80::1%eth0]")},
+		"host IPv4 brackets":    {validator: hostValidator{}, value: types.StringValue("[10.0.0.1]"), wantError: true},
+		"host IPv4 zone":        {validator: hostValidator{}, value: types.StringValue("10.0.0.1%eth0"), wantError: true},
+		"host empty zone":       {validator: hostValidator{}, value: types.StringValue("fe80::1%"), wantError: true},
+		"host invalid IPv6":     {validator: hostValidator{}, value: types.StringValue("2001:db8::g"), wantError: true},
+		"host with port":        {validator: hostValidator{}, value: types.StringValue("example.com:2222"), wantError: true},
+		"host empty":            {validator: hostValidator{}, value: types.StringValue(""), wantError: true},
+		"hosts entry port":      {validator: hostValidator{port: true}, value: types.StringValue("[fe80::1%eth0]:2222")},
+		"hosts entry name port": {validator: hostValidator{port: true}, value: types.StringValue("example.com:2222")},
+		"hosts entry IPv4 port": {validator: hostValidator{port: true}, value: types.StringValue("10.0.0.1:2222")},
+		"hosts entry IPv6":      {validator: hostValidator{port: true}, value: types.StringValue("2001:db8::1")},
+		"hosts entry bad port":  {validator: hostValidator{port: true}, value: types.StringValue("example.com:65536"), wantError: true},
+		"file mode":             {validator: fileModeValidator{}, value: types.StringValue("0644")},
+		"file mode setuid":      {validator: fileModeValidator{}, value: types.StringValue("4755")},
+		"file mode not octal":   {validator: fileModeValidator{}, value: types.StringValue("0899"), wantError: true},
+		"file mode too large":   {validator: fileModeValidator{}, value: types.StringValue("17777"), wantError: true},
+		"private key":           {validator: privateKeyValidator{}, value: types.StringValue(string(pem.EncodeToMemory(block)))},
+		"private key encrypted": {validator: privateKeyValidator{}, value: types.StringValue(string(pem.EncodeToMemory(encrypted)))},
+		"private key invalid":   {validator: privateKeyValidator{}, value: types.StringValue("not a key"), wantError: true},
+		"private key null":      {validator: privateKeyValidator{}, value: types.StringNull()},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := validator.StringRequest{Path: fwpath.Root("test"), ConfigValue: c.value}
+			var resp validator.StringResponse
+			c.validator.ValidateString(context.Background(), req, &resp)
+			if got := resp.Diagnostics.HasError(); got != c.wantError {
+				t.Errorf("expected error %t, got %v", c.wantError, resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestValidateResource(t *testing.T) {
+	s := sshtest.NewServer(t)
+	cases := map[string]struct {
+		modify    func(data *sshResourceModel)
+		wantError string
+	}{
+		"valid": {
+			modify: func(data *sshResourceModel) {},
+		},
+		"retry_delay not below timeout": {
+			modify: func(data *sshResourceModel) {
+				data.Timeout = types.StringValue("10s")
+				data.RetryDelay = types.StringValue("10s")
+			},
+			wantError: "retry_delay cannot be greater than timeout",
+		},
+		"missing user": {
+			modify: func(data *sshResourceModel) {
+				data.User = types.StringNull()
+			},
+			wantError: "user must be set",
+		},
+		"missing credentials": {
+			modify: func(data *sshResourceModel) {
+				data.Password = types.StringNull()
+			},
+			wantError: "'private_key' must be set",
+		},
+		"agent with password": {
+			modify: func(data *sshResourceModel) {
+				data.Agent = types.BoolValue(true)
+			},
+			wantError: "agent mode is enabled",
+		},
+		"unknown values are skipped": {
+			modify: func(data *sshResourceModel) {
+				data.Timeout = types.StringUnknown()
+				data.User = types.StringUnknown()
+				data.Password = types.StringUnknown()
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			data := testResourceModel(t, s, "true")
+			c.modify(data)
+
+			diags := validateResource(context.Background(), data)
+			if c.wantError == "" {
+				if diags.HasError() {
+					t.Errorf("expected no errors, got %v", diags)
+				}
+				return
+			}
+			if len(diags) != 1 || !strings.Contains(diags[0].Detail(), c.wantError) {
+				t.Errorf("expected error %q, got %v", c.wantError, diags)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestSSHResourceModel_writeOnly(t *testing.T) {
+	t.Setenv("SSH_PRIVATE_KEY_PASSPHRASE", "from-env")
+
+	data := sshResourceModel{
+		Host:                          types.StringValue("example.com"),
+		User:                          types.StringValue("root"),
+		Password:                      types.StringNull(),
+		PasswordWO:                    types.StringValue("secret"),
+		PrivateKey:                    types.StringValue("stored-key"),
+		PrivateKeyWO:                  types.StringNull(),
+		BastionPrivateKeyPassphraseWO: types.StringValue("bastion-passphrase"),
+	}
+	ssh := data.sshConfig()
+	if ssh.Password != "secret" {
+		t.Errorf("expected write-only password, got %q", ssh.Password)
+	}
+	if ssh.Key != "stored-key" {
+		t.Errorf("expected stored private key, got %q", ssh.Key)
+	}
+	if ssh.Passphrase != "from-env" {
+		t.Errorf("expected passphrase from environment, got %q", ssh.Passphrase)
+	}
+	if ssh.Bastion.Passphrase != "bastion-passphrase" {
+		t.Errorf("expected write-only bastion passphrase, got %q", ssh.Bastion.Passphrase)
+	}
+
+	data.clearWriteOnly()
+	for k, v := range data.writeOnly() {
+		if !v.IsNull() {
+			t.Errorf("expected %s to be cleared", k)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package ssh
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loafoe/easyssh-proxy/v2"
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+)
+
+const (
+	testRetryDelay = 50 * time.Millisecond
+	testTimeout    = 500 * time.Millisecond
+	testCommand    = "echo deployed"
+)
+
+func testServerSSHConfig(s *sshtest.Server) *easyssh.MakeConfig {
+	return connectionSettings{
+		Host:       s.Host,
+		Port:       s.Port,
+		User:       s.User,
+		PrivateKey: s.PrivateKey,
+	}.sshConfig()
+}
+
+func TestRunCommands_faults(t *testing.T) {
+	cases := map[string]struct {
+		faults         sshtest.Faults
+		ignoreNoMethod bool
+		// Bounds of the number of connections made, a maximum of 0 means unbounded
+		minConnections int
+		maxConnections int
+		wantErr        string
+	}{
+		"no faults": {
+			minConnections: 1,
+			maxConnections: 1,
+		},
+		"refused connections are retried": {
+			faults:         sshtest.Faults{RejectConnection: sshtest.FirstN(2)},
+			minConnections: 3,
+			maxConnections: 3,
+		},
+		"no supported methods remain fails immediately": {
+			faults:         sshtest.Faults{RejectAuth: sshtest.Always},
+			minConnections: 1,
+			maxConnections: 1,
+			wantErr:        "no supported methods remain",
+		},
+		"no supported methods remain is retried when ignored": {
+			faults:         sshtest.Faults{RejectAuth: sshtest.FirstN(2)},
+			ignoreNoMethod: true,
+			minConnections: 3,
+			maxConnections: 3,
+		},
+		"failing command is retried until timeout": {
+			faults:         sshtest.Faults{Command: sshtest.ExitWith(testCommand, 3)},
+			minConnections: 2,
+			wantErr:        "execution of command '" + testCommand + "' failed: context deadline exceeded: Process exited with status 3",
+		},
+		"hanging handshake times out": {
+			faults:         sshtest.Faults{HangHandshake: sshtest.Always},
+			minConnections: 1,
+			maxConnections: 1,
+			wantErr:        "waiting for host: context deadline exceeded",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := sshtest.NewServer(t)
+			s.SetFaults(c.faults)
+			retryConfig := SSHRetryConfig{
+				retryDelay:                   testRetryDelay,
+				timeout:                      testTimeout,
+				ignoreUnsupportedAuthMethods: c.ignoreNoMethod,
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+
+			start := time.Now()
+			_, diags, err := runCommands(ctx, []string{testCommand}, testServerSSHConfig(s), retryConfig, newConfig(os.DevNull))
+			if elapsed := time.Since(start); elapsed > 2*testTimeout {
+				t.Errorf("expected to give up after %s, took %s", testTimeout, elapsed)
+			}
+
+			if c.wantErr == "" {
+				if err != nil || diags.HasError() {
+					t.Fatalf("unexpected error: %v %v", err, diags)
+				}
+				if commands := s.Commands(); len(commands) != 1 || commands[0] != testCommand {
+					t.Errorf("expected command to run once, got %q", commands)
+				}
+			} else {
+				if err == nil || !diags.HasError() {
+					t.Fatalf("expected error %q", c.wantErr)
+				}
+				if !strings.Contains(diags[0].Summary, c.wantErr) {
+					t.Errorf("expected diagnostic %q, got %q", c.wantErr, diags[0].Summary)
+				}
+			}
+			n := s.Connections()
+			if n < c.minConnections || (c.maxConnections > 0 && n > c.maxConnections) {
+				t.Errorf("expected between %d and %d connections, got %d", c.minConnections, c.maxConnections, n)
+			}
+		})
+	}
+}
+
+func TestRunCommand_exitCode(t *testing.T) {
+	s := sshtest.NewServer(t)
+	s.SetFaults(sshtest.Faults{Command: sshtest.ExitWith("check", 4)})
+	retryConfig := SSHRetryConfig{retryDelay: testRetryDelay, timeout: testTimeout}
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	result, err := runCommand(ctx, "check", testServerSSHConfig(s), retryConfig, newConfig(os.DevNull))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ExitCode != 4 {
+		t.Errorf("expected exit code 4, got %d", result.ExitCode)
+	}
+	if n := len(s.Commands()); n != 1 {
+		t.Errorf("expected a failing command not to be retried, ran %d times", n)
+	}
+}
+
+func TestCopyFiles_faults(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	cases := map[string]struct {
+		faults        sshtest.Faults
+		wantTransfers int
+		wantErr       string
+	}{
+		"no faults": {
+			wantTransfers: 1,
+		},
+		"refused connection is retried": {
+			faults:        sshtest.Faults{RejectConnection: sshtest.FirstN(1)},
+			wantTransfers: 1,
+		},
+		"dropped transfer is retried": {
+			faults:        sshtest.Faults{DropTransfer: sshtest.FirstN(2)},
+			wantTransfers: 3,
+		},
+		"dropped transfers time out": {
+			faults:  sshtest.Faults{DropTransfer: sshtest.Always},
+			wantErr: "context deadline exceeded: wait: remote command exited without exit status",
+		},
+		"hanging handshake times out": {
+			faults:  sshtest.Faults{HangHandshake: sshtest.Always},
+			wantErr: "waiting for host: context deadline exceeded",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := sshtest.NewServer(t)
+			s.SetFaults(c.faults)
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+
+			files := []provisionFile{{Content: content, Destination: s.Path("app.conf")}}
+			err := copyFiles(ctx, testRetryDelay, testServerSSHConfig(s), TransferProtocolSCP, newConfig(os.DevNull), files)
+
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("expected error %q, got %v", c.wantErr, err)
+
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, err := os.ReadFile(s.Path("app.conf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != content {
+				t.Errorf("expected complete content after retries, got %d bytes", len(data))
+			}
+			if n := s.Transfers(); n != c.wantTransfers {
+				t.Errorf("expected %d transfers, got %d", c.wantTransfers, n)
+			}
+		})
+	}
+}
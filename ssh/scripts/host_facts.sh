@@ -0,0 +1,44 @@
+# Probe used by the ssh_host_facts data source. Prints one key=value pair per line,
+# ip_address is repeated for every global address. Keep this POSIX sh compatible.
+if [ -r /etc/os-release ]; then
+  . /etc/os-release
+fi
+echo "os_id=${ID:-$(uname -s | tr '[:upper:]' '[:lower:]')}"
+echo "os_name=${NAME:-$(uname -s)}"
+echo "os_version=${VERSION_ID:-$(uname -r)}"
+echo "kernel=$(uname -r)"
+echo "architecture=$(uname -m)"
+
+cpus=$(getconf _NPROCESSORS_ONLN 2>/dev/null || nproc 2>/dev/null || grep -c '^processor' /proc/cpuinfo 2>/dev/null)
+echo "cpu_count=${cpus:-0}"
+mem=$(awk '/^MemTotal:/ { printf "%.0f", $2 / 1024 }' /proc/meminfo 2>/dev/null)
+echo "memory_mb=${mem:-0}"
+
+init=unknown
+if [ -d /run/systemd/system ]; then
+  init=systemd
+elif [ -x /sbin/openrc-run ] || [ -d /run/openrc ]; then
+  init=openrc
+elif [ -d /etc/runit ] || [ -d /run/runit ]; then
+  init=runit
+elif [ -f /etc/inittab ]; then
+  init=sysvinit
+fi
+echo "init_system=$init"
+
+pm=unknown
+for p in apt-get dnf yum zypper apk pacman; do
+  if command -v "$p" >/dev/null 2>&1; then
+    pm=$p
+    break
+  fi
+done
+echo "package_manager=$pm"
+
+if command -v ip >/dev/null 2>&1; then
+  ip -o addr show scope global | awk '{ split($4, a, "/"); print "ip_address=" a[1] }'
+else
+  for a in $(hostname -I 2>/dev/null); do
+    echo "ip_address=$a"
+  done
+fi
@@ -1,20 +1,9 @@
 package ssh
 
 import (
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
 )
 
-func sensitiveResourceResource() *schema.Resource {
-	return &schema.Resource{
-		CreateContext: resourceResourceCreate,
-		ReadContext:   resourceResourceRead,
-		UpdateContext: resourceResourceUpdate,
-		DeleteContext: resourceResourceDelete,
-		CustomizeDiff: customDiff,
-		SchemaVersion: 1,
-		Importer: &schema.ResourceImporter{
-			StateContext: schema.ImportStatePassthroughContext,
-		},
-		Schema: sshResourceSchema(true),
-	}
+func newSensitiveResource() resource.Resource {
+	return &sshResource{sensitive: true}
 }
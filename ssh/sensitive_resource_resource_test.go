@@ -14,65 +14,65 @@ func TestAccSensitiveResourceResource_basic(t *testing.T) {
 
 	resourceName := "ssh_sensitive_resource.test"
 	randomName := acctest.RandStringFromCharSet(10, acctest.CharSetAlpha)
-	username := acc.AccUsername()
+	target := acc.NewTarget(t)
 
 	resource.Test(t, resource.TestCase{
 		PreCheck: func() {
 			acc.PreCheck(t)
 		},
-		ProviderFactories: acc.ProviderFactories,
+		ProtoV5ProviderFactories: acc.ProtoV5ProviderFactories,
+		CheckDestroy:             testAccCheckTargetFileRemoved(target, randomName),
 		Steps: []resource.TestStep{
 			{
 				ResourceName: resourceName,
-				Config:       testAccSensitiveResourceResource(randomName),
+				Config:       testAccSensitiveResourceResource(target, randomName),
 				Check: resource.ComposeTestCheckFunc(
-					resource.TestCheckResourceAttr(resourceName, "user", username)),
+					resource.TestCheckResourceAttr(resourceName, "user", target.User),
+					resource.TestCheckResourceAttr(resourceName, "result", "hello "+randomName+"\n"),
+					testAccCheckTargetFile(target, randomName, "hello "+randomName)),
 			},
 		},
 	})
 }
 
-func testAccSensitiveResourceResource(random string) string {
-	username := acc.AccUsername()
-	privateKey := acc.AccPrivateKey()
-	hostname := acc.AccHostname()
-
+func testAccSensitiveResourceResource(target acc.Target, random string) string {
 	return fmt.Sprintf(`
 
 resource "ssh_sensitive_resource" "test" {
-	host        = "%s"
-    user        = "%s"
-    agent       = false
-    private_key = "%s"
+	host        = "%[1]s"
+	port        = %[2]s
+	user        = "%[3]s"
+	agent       = false
+	private_key = <<-EOT
+%[4]s
+EOT
+
+	transfer_protocol = "sftp"
+
+	file {
+		content     = "hello %[6]s"
+		destination = "%[5]s/%[6]s"
+	}
 
-    commands = [
-       "date > /tmp/%s"
-    ]
+	commands = [
+		"cat %[5]s/%[6]s"
+	]
 }
 
 resource "ssh_sensitive_resource" "destroy" {
-	host        = "%s"
-    user        = "%s"
-    agent       = false
-    private_key = "%s"
- 
-    when        = "destroy"
+	host        = "%[1]s"
+	port        = %[2]s
+	user        = "%[3]s"
+	agent       = false
+	private_key = <<-EOT
+%[4]s
+EOT
 
-    commands = [
-        "rm /tmp/%s"
-    ]
-}
-`,
-		// SSH Resource test
-		hostname,
-		username,
-		privateKey,
-		random,
+	when        = "destroy"
 
-		// SSH Resource destroy
-		hostname,
-		username,
-		privateKey,
-		random,
-	)
+	commands = [
+		"rm %[5]s/%[6]s"
+	]
+}
+`, target.Host, target.Port, target.User, target.PrivateKey, target.Dir, random)
 }
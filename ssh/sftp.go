@@ -0,0 +1,94 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/loafoe/easyssh-proxy/v2"
+	"github.com/pkg/sftp"
+)
+
+const (
+	TransferProtocolSCP  = "scp"
+	TransferProtocolSFTP = "sftp"
+	TransferProtocolAuto = "auto"
+)
+
+func transferProtocolSchema() *schema.Schema {
+	return &schema.Schema{
+		Description:  "The protocol used to transfer files. Options are 'scp', 'sftp' or 'auto'",
+		Type:         schema.TypeString,
+		Optional:     true,
+		Default:      TransferProtocolSCP,
+		ValidateFunc: validation.StringInSlice([]string{TransferProtocolSCP, TransferProtocolSFTP, TransferProtocolAuto}, false),
+	}
+}
+
+// errSFTPUnavailable is returned when the remote does not offer the sftp subsystem
+var errSFTPUnavailable = errors.New("sftp subsystem unavailable")
+
+// writeFile writes size bytes from reader to destination using the requested transfer protocol.
+// In auto mode SFTP is tried first, falling back to SCP when the sftp subsystem is not available
+func writeFile(ssh *easyssh.MakeConfig, protocol string, reader io.Reader, size int64, destination string, mode os.FileMode, modTime time.Time) error {
+	switch protocol {
+	case TransferProtocolSFTP:
+		return sftpWriteFile(ssh, reader, destination, mode, modTime)
+	case TransferProtocolAuto:
+		err := sftpWriteFile(ssh, reader, destination, mode, modTime)
+		if !errors.Is(err, errSFTPUnavailable) {
+			return err
+		}
+		return ssh.WriteFile(reader, size, destination)
+	default:
+		return ssh.WriteFile(reader, size, destination)
+	}
+}
+
+// sftpWriteFile uploads the content of reader to destination over the sftp subsystem.
+// Missing parent directories are created, mode is applied before any content is written
+// and modTime, when set, is preserved on the remote file
+func sftpWriteFile(ssh *easyssh.MakeConfig, reader io.Reader, destination string, mode os.FileMode, modTime time.Time) error {
+	session, client, err := ssh.Connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	_ = session.Close()
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSFTPUnavailable, err)
+	}
+	defer sftpClient.Close()
+
+	if err := sftpClient.MkdirAll(path.Dir(destination)); err != nil {
+		return fmt.Errorf("creating parent directories of %s: %w", destination, err)
+	}
+	f, err := sftpClient.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if _, err := io.Copy(f, reader); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if !modTime.IsZero() {
+		if err := sftpClient.Chtimes(destination, modTime, modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package ssh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loafoe/terraform-provider-ssh/internal/acc/sshtest"
+)
+
+func TestSFTPWriteFile(t *testing.T) {
+	s := sshtest.NewServer(t)
+	destination := s.Path("etc/app/app.yaml")
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if err := sftpWriteFile(testServerSSHConfig(s), strings.NewReader("debug: true\n"), destination, 0640, modTime); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if info, err := os.Stat(filepath.Dir(destination)); err != nil || !info.IsDir() {
+		t.Fatalf("expected the parent directories to be created, got %v", err)
+	}
+	info, err := os.Stat(destination)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0640 {
+		t.Errorf("expected mode 0640, got %o", mode)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("expected modification time %s, got %s", modTime, info.ModTime())
+	}
+	if content, _ := os.ReadFile(destination); string(content) != "debug: true\n" {
+		t.Errorf("unexpected content: %q", content)
+	}
+
+	// Without a modification time the file keeps the time of the write
+	if err := sftpWriteFile(testServerSSHConfig(s), strings.NewReader("debug: false\n"), destination, 0600, time.Time{}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info, err = os.Stat(destination); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.ModTime().Equal(modTime) {
+		t.Errorf("expected the modification time to be updated")
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("expected mode 0600, got %o", mode)
+	}
+}
+
+func TestWriteFile_protocols(t *testing.T) {
+	cases := map[string]struct {
+		protocol     string
+		rejectSFTP   bool
+		wantErr      error
+		wantTransfer bool
+	}{
+		"sftp":                       {protocol: TransferProtocolSFTP},
+		"sftp unavailable":           {protocol: TransferProtocolSFTP, rejectSFTP: true, wantErr: errSFTPUnavailable},
+		"auto uses sftp":             {protocol: TransferProtocolAuto},
+		"auto falls back to scp":     {protocol: TransferProtocolAuto, rejectSFTP: true, wantTransfer: true},
+		"scp":                        {protocol: TransferProtocolSCP, wantTransfer: true},
+		"scp without sftp subsystem": {protocol: TransferProtocolSCP, rejectSFTP: true, wantTransfer: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := sshtest.NewServer(t)
+			s.SetFaults(sshtest.Faults{RejectSFTP: c.rejectSFTP})
+			destination := s.Path("app.yaml")
+			content := "debug: true\n"
+
+			err := writeFile(testServerSSHConfig(s), c.protocol, strings.NewReader(content), int64(len(content)), destination, 0644, time.Time{})
+			if c.wantErr != nil {
+				if !errors.Is(err, c.wantErr) {
+					t.Fatalf("expected %v, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if got, _ := os.ReadFile(destination); string(got) != content {
+				t.Errorf("unexpected content: %q", got)
+			}
+			// The server counts the files it receives with scp
+			if transferred := s.Transfers() > 0; transferred != c.wantTransfer {
+				t.Errorf("expected an scp transfer %t, got %t", c.wantTransfer, transferred)
+			}
+		})
+	}
+}
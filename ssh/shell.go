@@ -0,0 +1,8 @@
+package ssh
+
+import "strings"
+
+// shellQuote quotes s for use as a single argument in a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
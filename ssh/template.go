@@ -0,0 +1,57 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// renderTemplate renders the Go text/template in source using vars as data.
+// Referencing a variable which is not in vars is an error
+func renderTemplate(source string, vars map[string]interface{}) (string, error) {
+	text, err := os.ReadFile(source)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(source).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return "", err
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, vars); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
+
+// validateFileTemplates renders all template file blocks during plan so
+// template errors are reported before anything is provisioned
+func validateFileTemplates(files []fileModel) error {
+	for _, f := range files {
+		if !f.Template.ValueBool() {
+			continue
+		}
+		if f.Source.ValueString() == "" || f.Vars.IsUnknown() {
+			// Either not set or not known yet, collectFilesToCreate reports the former
+			continue
+		}
+		vars := make(map[string]interface{})
+		for k, v := range f.Vars.Elements() {
+			if v.IsUnknown() {
+				vars = nil
+				break
+			}
+			vars[k] = v.(types.String).ValueString()
+		}
+		if vars == nil {
+			continue
+		}
+		if _, err := renderTemplate(f.Source.ValueString(), vars); err != nil {
+			return fmt.Errorf("rendering template for file %s: %w", f.Destination.ValueString(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "app.yaml.tmpl")
+	if err := os.WriteFile(source, []byte("listen: {{ .port }}\n"), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	rendered, err := renderTemplate(source, map[string]interface{}{"port": "8080"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rendered != "listen: 8080\n" {
+		t.Errorf("unexpected rendering: %q", rendered)
+	}
+
+	if _, err := renderTemplate(source, map[string]interface{}{}); err == nil {
+		t.Errorf("expected error on missing variable")
+	}
+}